@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"math/rand"
+
+	"github.com/spf13/cobra"
+	"github.com/wchresta/passdraw/pkg/audit"
+	"github.com/wchresta/passdraw/pkg/runner"
+	"github.com/wchresta/passdraw/pkg/simulate"
+)
+
+type auditCmd struct {
+	inputPath  string
+	runs       int
+	workers    int
+	seed       int64
+	alpha      float64
+	strategies []string
+}
+
+func init() {
+	cmd := auditCmd{}
+
+	var cobraCmd = &cobra.Command{
+		Use:   "audit",
+		Short: "Check the fairness properties for an input.",
+		Long: `Simulates many draws of an input and checks the fairness properties of the README:
+
+  minimum-probability      users without constraints get a pass with probability of at least n_t/m_t
+  equal-treatment          users with the same constraints and weights get the same probability
+  dependency-monotonicity  dependencies do not lower the probability of users without dependencies
+
+Lists every user violating a property, with the estimated gap and a p-value.
+Given multiple strategies, audits each of them with the same seed.`,
+		Run: cmd.Audit,
+	}
+
+	rootCmd.AddCommand(cobraCmd)
+
+	cobraCmd.Flags().StringVar(&cmd.inputPath, "input", "", "Path to take the input from. Must be in json format")
+	cobraCmd.Flags().IntVar(&cmd.runs, "runs", 100000, "How many runs")
+	cobraCmd.Flags().IntVar(&cmd.workers, "workers", 0, "How many runs to do in parallel. Defaults to the number of CPUs")
+	cobraCmd.Flags().Int64Var(&cmd.seed, "seed", 0, "Seed for the simulation. Random if not set")
+	cobraCmd.Flags().Float64Var(&cmd.alpha, "alpha", 0.01, "Significance level for each property")
+	cobraCmd.Flags().StringSliceVar(&cmd.strategies, "strategy", []string{runner.Backward.Name()}, "Strategies to draw the passes with; any of backward, forward, ticket-order, tickets")
+	cobraCmd.MarkFlagRequired("input")
+}
+
+func (c *auditCmd) Audit(cmd *cobra.Command, args []string) {
+	conf, err := readConfig(c.inputPath)
+	if err != nil {
+		cmd.PrintErrln(err)
+		return
+	}
+
+	var strategies []runner.Strategy
+	for _, name := range c.strategies {
+		strategy, err := runner.StrategyByName(name)
+		if err != nil {
+			cmd.PrintErrln(err)
+			return
+		}
+		strategies = append(strategies, strategy)
+	}
+
+	seed := c.seed
+	if !cmd.Flags().Changed("seed") {
+		seed = rand.Int63()
+	}
+
+	for _, strategy := range strategies {
+		report, err := audit.Audit(cmd.Context(), conf.Problem().AllUsers(), conf.Availabilities(), audit.Options{
+			Options: simulate.Options{
+				Runs:     c.runs,
+				Workers:  c.workers,
+				Seed:     seed,
+				Strategy: strategy,
+			},
+			Alpha: c.alpha,
+		})
+		if err != nil {
+			cmd.PrintErrf("Audit failed: %s\n", err)
+			return
+		}
+
+		cmd.Printf("Audited %d runs of strategy %s with seed %d at significance level %g:\n", report.Runs, strategy.Name(), seed, c.alpha)
+		for _, prop := range audit.Properties {
+			var violations []audit.Violation
+			for _, v := range report.Violations {
+				if v.Property == prop {
+					violations = append(violations, v)
+				}
+			}
+
+			cmd.Printf("%s - checked %d users, found %d violations\n", prop, report.Checked[prop], len(violations))
+			for _, v := range violations {
+				cmd.Printf(" x %s: estimated %.4f, expected %.4f, gap %+.4f, p-value %.2g\n", v.User, v.Estimated, v.Expected, v.Gap, v.PValue)
+			}
+		}
+	}
+}
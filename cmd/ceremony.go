@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wchresta/passdraw/pkg/input"
+	"github.com/wchresta/passdraw/pkg/runner"
+)
+
+type ceremonyCmd struct {
+	inputPath string
+	seed      int64
+	strategy  string
+	dangling  string
+	pace      time.Duration
+}
+
+func init() {
+	cmd := ceremonyCmd{}
+
+	var cobraCmd = &cobra.Command{
+		Use:   "ceremony",
+		Short: "Draw passes step by step, e.g. for a live draw on stream.",
+		Long: `Draws the passes like run, but shows every step of the draw as it happens,
+waiting between the users drawn: the rounds, the users drawn, the users refused
+with them because they depend on them or are in a group with them, and the
+partitions that are done.
+
+The result is the same as the one of run with the same input, strategy and seed.`,
+		Run: cmd.Ceremony,
+	}
+
+	rootCmd.AddCommand(cobraCmd)
+
+	cobraCmd.Flags().StringVar(&cmd.inputPath, "input", "", "Path to take the input from. Must be in json format")
+	cobraCmd.Flags().StringVar(&cmd.strategy, "strategy", runner.Backward.Name(), "Strategy to draw the passes with; one of backward, forward, ticket-order, tickets")
+	cobraCmd.Flags().StringVar(&cmd.dangling, "dangling", "", "What happens to users depending on users that did not register; one of fail, refuse, ignore. Overrides DanglingDeps of the input")
+	cobraCmd.Flags().Int64Var(&cmd.seed, "seed", 0, "Seed for the random draw. The same input and seed always give the same result. Random if not set")
+	cobraCmd.Flags().DurationVar(&cmd.pace, "pace", 2*time.Second, "How long to wait before every user drawn and every partition closed")
+	cobraCmd.MarkFlagRequired("input")
+}
+
+func (c *ceremonyCmd) Ceremony(cmd *cobra.Command, args []string) {
+	strategy, err := runner.StrategyByName(c.strategy)
+	if err != nil {
+		cmd.PrintErrln(err)
+		return
+	}
+
+	seed := c.seed
+	if !cmd.Flags().Changed("seed") {
+		seed = rand.Int63()
+	}
+
+	conf, err := readConfigWithPolicy(c.inputPath, input.DanglingPolicy(c.dangling))
+	if err != nil {
+		cmd.PrintErrln(err)
+		return
+	}
+	avail := conf.Availabilities()
+	availMap := make(map[runner.Partition]runner.Availability)
+	for _, a := range avail {
+		availMap[a.Partition] = a
+	}
+
+	run := conf.RunnerWithRand(rand.New(rand.NewSource(seed)))
+	run.SetStrategy(strategy)
+	events, err := run.Events(avail)
+	if err != nil {
+		cmd.PrintErrf("Run failed: %s", err)
+		return
+	}
+
+	cmd.Printf("Drawing passes with strategy %s and seed %d\n", strategy.Name(), seed)
+	for part, a := range sortedKeys(availMap) {
+		cmd.Printf("%s - %d users registered for %d passes\n", part, len(run.Users(part)), a.Available)
+	}
+
+	for e := range events {
+		switch e.Kind {
+		case runner.EventUserDrawn, runner.EventPartitionClosed:
+			select {
+			case <-time.After(c.pace):
+			case <-cmd.Context().Done():
+				cmd.PrintErrln("Ceremony canceled")
+				return
+			}
+		}
+
+		switch e.Kind {
+		case runner.EventStageStarted:
+			if e.Stage == 0 {
+				cmd.Println("Stage 0 - users are candidates for the partition they registered for")
+			} else {
+				cmd.Printf("Stage %d - refused users are candidates for their next fallback partition\n", e.Stage)
+			}
+		case runner.EventRoundStarted:
+			cmd.Printf("Round %d\n", e.Round)
+		case runner.EventUserExcluded:
+			cmd.Printf(" ! %s - %s is excluded\n", e.Partition, e.User)
+		case runner.EventUserDrawn:
+			cmd.Printf(" x %s - %s is drawn and does not get a pass\n", e.Partition, e.User)
+		case runner.EventCascadeRefusal:
+			chain := make([]string, len(e.Chain))
+			for i, u := range e.Chain {
+				chain[i] = string(u)
+			}
+			cmd.Printf("   x %s - %s does not get a pass either, through %s\n", e.Partition, e.User, strings.Join(chain, " > "))
+		case runner.EventPartitionClosed:
+			cmd.Printf(" = %s - no more users are drawn for this partition\n", e.Partition)
+		case runner.EventFinished:
+			printSolution(cmd, seed, e.Solution, availMap)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wchresta/passdraw/pkg/commit"
+)
+
+type commitCmd struct {
+	inputPath string
+	outPath   string
+}
+
+func init() {
+	cmd := commitCmd{}
+
+	var cobraCmd = &cobra.Command{
+		Use:   "commit",
+		Short: "Commit to an input before the draw.",
+		Long: `Hashes the canonical form of the input and writes a commitment file.
+
+Publish the commitment before the beacon value is known. Afterwards, use
+"passdraw draw" to draw with a seed derived from the commitment and the beacon.`,
+		Run: cmd.Commit,
+	}
+
+	rootCmd.AddCommand(cobraCmd)
+
+	cobraCmd.Flags().StringVar(&cmd.inputPath, "input", "", "Path to take the input from. Must be in json format")
+	cobraCmd.Flags().StringVar(&cmd.outPath, "out", "", "Path to write the commitment to. Defaults to stdout")
+	cobraCmd.MarkFlagRequired("input")
+}
+
+func (c *commitCmd) Commit(cmd *cobra.Command, args []string) {
+	conf, err := readConfig(c.inputPath)
+	if err != nil {
+		cmd.PrintErrln(err)
+		return
+	}
+
+	commitment, err := commit.New(conf)
+	if err != nil {
+		cmd.PrintErrf("cannot create commitment: %s\n", err)
+		return
+	}
+
+	if err := writeJSON(cmd, c.outPath, commitment); err != nil {
+		cmd.PrintErrln(err)
+	}
+}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wchresta/passdraw/pkg/commit"
+	"github.com/wchresta/passdraw/pkg/input"
+	"github.com/wchresta/passdraw/pkg/runner"
+)
+
+type drawCmd struct {
+	inputPath      string
+	commitmentPath string
+	beaconPath     string
+	outPath        string
+}
+
+func init() {
+	cmd := drawCmd{}
+
+	var cobraCmd = &cobra.Command{
+		Use:   "draw",
+		Short: "Draw passes with a seed derived from a commitment and a beacon.",
+		Long: `Checks that the input matches the commitment and draws passes with a seed
+derived from the committed input hash and the published beacon value.
+
+The beacon file must contain a value that was published after the commitment,
+e.g. the result of a public lottery.`,
+		Run: cmd.Draw,
+	}
+
+	rootCmd.AddCommand(cobraCmd)
+
+	cobraCmd.Flags().StringVar(&cmd.inputPath, "input", "", "Path to take the input from. Must be in json format")
+	cobraCmd.Flags().StringVar(&cmd.commitmentPath, "commitment", "", "Path to the commitment written by `passdraw commit`")
+	cobraCmd.Flags().StringVar(&cmd.beaconPath, "beacon", "", "Path to a file containing the published beacon value")
+	cobraCmd.Flags().StringVar(&cmd.outPath, "out", "", "Path to write the solution to. Defaults to stdout")
+	cobraCmd.MarkFlagRequired("input")
+	cobraCmd.MarkFlagRequired("commitment")
+	cobraCmd.MarkFlagRequired("beacon")
+}
+
+func (c *drawCmd) Draw(cmd *cobra.Command, args []string) {
+	conf, err := readConfig(c.inputPath)
+	if err != nil {
+		cmd.PrintErrln(err)
+		return
+	}
+
+	solution, seed, err := drawCommitted(conf, c.commitmentPath, c.beaconPath)
+	if err != nil {
+		cmd.PrintErrln(err)
+		return
+	}
+
+	cmd.PrintErrf("Drew passes with seed %d\n", seed)
+	if err := writeJSON(cmd, c.outPath, solution); err != nil {
+		cmd.PrintErrln(err)
+	}
+}
+
+// drawCommitted checks the input against the commitment at commitmentPath,
+// and runs the draw with the seed derived from the beacon at beaconPath.
+func drawCommitted(conf *input.RunConfig, commitmentPath string, beaconPath string) (*runner.Solution, int64, error) {
+	var commitment commit.Commitment
+	if err := readJSON(commitmentPath, &commitment); err != nil {
+		return nil, 0, err
+	}
+	if err := commitment.Check(conf); err != nil {
+		return nil, 0, err
+	}
+
+	beacon, err := os.ReadFile(beaconPath)
+	if err != nil {
+		return nil, 0, fmt.Errorf("cannot read beacon file %s: %w", beaconPath, err)
+	}
+	seed, err := commitment.Seed(beacon)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	run := conf.RunnerWithRand(rand.New(rand.NewSource(seed)))
+	solution, err := run.Run(conf.Availabilities())
+	if err != nil {
+		return nil, 0, fmt.Errorf("run failed: %w", err)
+	}
+	return solution, seed, nil
+}
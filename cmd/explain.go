@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wchresta/passdraw/pkg/runner"
+)
+
+type explainCmd struct {
+	solutionPath string
+}
+
+func init() {
+	cmd := explainCmd{}
+
+	var cobraCmd = &cobra.Command{
+		Use:   "explain <user>",
+		Short: "Explain why a user did or did not get a pass.",
+		Long: `Prints the outcome of a user in a solution, and every time the user was refused:
+in which stage and round of the draw, and whether the user was drawn directly,
+or refused because a user they depend on, or a member of their group, was refused.
+For such cascaded refusals, the chain of users the refusal cascaded through is shown,
+starting with the user that was drawn.`,
+		Args: cobra.ExactArgs(1),
+		Run:  cmd.Explain,
+	}
+
+	rootCmd.AddCommand(cobraCmd)
+
+	cobraCmd.Flags().StringVar(&cmd.solutionPath, "solution", "", "Path to the solution written by `passdraw draw`")
+	cobraCmd.MarkFlagRequired("solution")
+}
+
+func (c *explainCmd) Explain(cmd *cobra.Command, args []string) {
+	user := runner.UserID(args[0])
+
+	var solution runner.Solution
+	if err := readJSON(c.solutionPath, &solution); err != nil {
+		cmd.PrintErrln(err)
+		return
+	}
+
+	refusals := solution.Refusals[user]
+	found := len(refusals) > 0
+	for part, partPass := range sortedKeys(solution.Passes) {
+		if slices.Contains(partPass, user) {
+			cmd.Printf("%s got a pass for partition %s.\n", user, part)
+			found = true
+		}
+	}
+	for part, waitlist := range sortedKeys(solution.Waitlist) {
+		if i := slices.Index(waitlist, user); i >= 0 {
+			cmd.Printf("%s did not get a pass, and is number %d of %d on the waitlist of partition %s.\n", user, i+1, len(waitlist), part)
+			found = true
+		}
+	}
+	if !found {
+		cmd.PrintErrf("user %s is not part of the solution\n", user)
+		return
+	}
+	if solution.Refusals == nil {
+		cmd.PrintErrln("the solution does not explain its refusals; it was drawn by an older version")
+		return
+	}
+
+	if len(refusals) == 0 {
+		cmd.Printf("%s was never refused.\n", user)
+		return
+	}
+	cmd.Printf("%s was refused for the following partitions:\n", user)
+	for _, r := range refusals {
+		cmd.Printf(" x %s - stage %d, round %d: %s\n", r.Partition, r.Stage, r.Round, describeRefusal(r))
+	}
+}
+
+// describeRefusal explains the reason of the refusal in words.
+func describeRefusal(r runner.Refusal) string {
+	switch r.Reason {
+	case runner.ReasonDrawn:
+		return "drawn"
+	case runner.ReasonExcluded:
+		return "excluded before the draw"
+	}
+
+	if len(r.Chain) == 0 {
+		// Refused when becoming a candidate for a fallback, without a single user to blame.
+		if r.Reason == runner.ReasonGroup {
+			return "its group can no longer get a pass"
+		}
+		return "its dependencies can no longer be met"
+	}
+
+	chain := make([]string, len(r.Chain))
+	for i, u := range r.Chain {
+		chain[i] = string(u)
+	}
+	cause := r.Chain[len(r.Chain)-1]
+	how := "depends on " + string(cause)
+	if r.Reason == runner.ReasonGroup {
+		how = "is in a group with " + string(cause)
+	}
+	return how + ", who was refused; cascaded through " + strings.Join(chain, " > ")
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/wchresta/passdraw/pkg/runner"
+)
+
+func TestDescribeRefusal(t *testing.T) {
+	for _, tc := range []struct {
+		refusal runner.Refusal
+		want    string
+	}{
+		{refusal: runner.Refusal{Reason: runner.ReasonDrawn}, want: "drawn"},
+		{refusal: runner.Refusal{Reason: runner.ReasonExcluded}, want: "excluded before the draw"},
+		{
+			refusal: runner.Refusal{Reason: runner.ReasonDependency, Chain: []runner.UserID{"A", "B"}},
+			want:    "depends on B, who was refused; cascaded through A > B",
+		},
+		{
+			refusal: runner.Refusal{Reason: runner.ReasonGroup, Chain: []runner.UserID{"A"}},
+			want:    "is in a group with A, who was refused; cascaded through A",
+		},
+		{refusal: runner.Refusal{Reason: runner.ReasonDependency}, want: "its dependencies can no longer be met"},
+	} {
+		if got := describeRefusal(tc.refusal); got != tc.want {
+			t.Errorf("describeRefusal(%v) = %q, want %q", tc.refusal, got, tc.want)
+		}
+	}
+}
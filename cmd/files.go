@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wchresta/passdraw/pkg/input"
+)
+
+// readConfig reads and validates the input file at path.
+func readConfig(path string) (*input.RunConfig, error) {
+	return readConfigWithPolicy(path, "")
+}
+
+// readConfigWithPolicy reads the input file at path, and validates it with the
+// dangling dependency policy, if not empty, instead of the one of the input.
+func readConfigWithPolicy(path string, dangling input.DanglingPolicy) (*input.RunConfig, error) {
+	inputBs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read input file %s: %w", path, err)
+	}
+
+	conf, err := input.Parse(inputBs)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse input file %s: %w", path, err)
+	}
+	if dangling != "" {
+		conf.DanglingDeps = dangling
+	}
+	if errs := conf.Validate(); errs != nil {
+		return nil, fmt.Errorf("cannot parse input file %s: %w", path, errs)
+	}
+	return conf, nil
+}
+
+// readJSON unmarshals the json file at path into v.
+func readJSON(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("cannot read file %s: %w", path, err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("cannot parse file %s: %w", path, err)
+	}
+	return nil
+}
+
+// writeJSON writes v as json to path, or to the command output if path is empty.
+func writeJSON(cmd *cobra.Command, path string, v any) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	if path == "" {
+		_, err = cmd.OutOrStdout().Write(b)
+		return err
+	}
+	if err := os.WriteFile(path, b, 0o644); err != nil {
+		return fmt.Errorf("cannot write file %s: %w", path, err)
+	}
+	return nil
+}
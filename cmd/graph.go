@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wchresta/passdraw/pkg/render"
+	"github.com/wchresta/passdraw/pkg/runner"
+)
+
+type graphCmd struct {
+	inputPath    string
+	solutionPath string
+	format       string
+	all          bool
+	outPath      string
+}
+
+func init() {
+	cmd := graphCmd{}
+
+	var cobraCmd = &cobra.Command{
+		Use:   "graph",
+		Short: "Draw the dependencies between users.",
+		Long: `Writes the dependency graph of the input as Graphviz DOT or Mermaid flowchart.
+Users are grouped by the partition they registered for. Deps are solid arrows,
+DepGroups dashed arrows, and members of a group are connected by dotted lines.
+
+With --solution, users are colored by their outcome: pass, waitlisted or refused.
+This shows why users that depend on each other were refused together.
+
+  passdraw graph --input event.json --solution solution.json | dot -Tsvg > graph.svg`,
+		Run: cmd.Graph,
+	}
+
+	rootCmd.AddCommand(cobraCmd)
+
+	cobraCmd.Flags().StringVar(&cmd.inputPath, "input", "", "Path to take the input from. Must be in json format")
+	cobraCmd.Flags().StringVar(&cmd.solutionPath, "solution", "", "Path to a solution to color users by their outcome")
+	cobraCmd.Flags().StringVar(&cmd.format, "format", string(render.Dot), fmt.Sprintf("Output format; one of %v", render.Formats))
+	cobraCmd.Flags().BoolVar(&cmd.all, "all", false, "Also draw users without any dependencies")
+	cobraCmd.Flags().StringVar(&cmd.outPath, "out", "", "Path to write the graph to. Defaults to stdout")
+	cobraCmd.MarkFlagRequired("input")
+}
+
+func (c *graphCmd) Graph(cmd *cobra.Command, args []string) {
+	conf, err := readConfig(c.inputPath)
+	if err != nil {
+		cmd.PrintErrln(err)
+		return
+	}
+	problem := conf.Problem()
+
+	opts := render.Options{All: c.all}
+	if c.solutionPath != "" {
+		var solution runner.Solution
+		if err := readJSON(c.solutionPath, &solution); err != nil {
+			cmd.PrintErrln(err)
+			return
+		}
+		opts.Outcomes = render.Outcomes(problem, &solution)
+	}
+
+	var out bytes.Buffer
+	if err := render.Graph(&out, render.Format(c.format), problem, opts); err != nil {
+		cmd.PrintErrln(err)
+		return
+	}
+
+	if c.outPath == "" {
+		if _, err := cmd.OutOrStdout().Write(out.Bytes()); err != nil {
+			cmd.PrintErrln(err)
+		}
+		return
+	}
+	if err := os.WriteFile(c.outPath, out.Bytes(), 0o644); err != nil {
+		cmd.PrintErrf("cannot write file %s: %s\n", c.outPath, err)
+	}
+}
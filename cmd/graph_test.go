@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGraphWritesToStdout(t *testing.T) {
+	for _, tc := range []struct {
+		format string
+		want   string
+	}{
+		{format: "dot", want: "digraph passdraw {"},
+		{format: "mermaid", want: "flowchart LR"},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer stdout.Close()
+			realStdout := os.Stdout
+			os.Stdout = stdout
+			defer func() { os.Stdout = realStdout }()
+
+			rootCmd.SetArgs([]string{"graph", "--input", "../testdata/medium_dance_event.json", "--format", tc.format})
+			if err := rootCmd.Execute(); err != nil {
+				t.Fatalf("graph failed unexpectedly: %s", err)
+			}
+
+			got, err := os.ReadFile(stdout.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(string(got), tc.want) {
+				t.Errorf("graph did not write %s to stdout, got %q", tc.format, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/spf13/cobra"
+	"github.com/wchresta/passdraw/pkg/runner"
+	"github.com/wchresta/passdraw/pkg/simulate"
+)
+
+type probabilitiesCmd struct {
+	inputPath string
+	passes    int
+	exact     bool
+	runs      int
+	workers   int
+	seed      int64
+}
+
+func init() {
+	cmd := probabilitiesCmd{}
+
+	var cobraCmd = &cobra.Command{
+		Use:   "probabilities",
+		Short: "Get the probability of every user to get a pass",
+		Long: `Reports, for every user, the probability to get a pass.
+
+By default, the probabilities are estimated by simulating many draws.
+With --exact, they are computed exactly by going through every possible draw.
+This is only feasible for small inputs of up to a few dozen users.`,
+		Run: cmd.Probabilities,
+	}
+
+	rootCmd.AddCommand(cobraCmd)
+
+	cobraCmd.Flags().StringVar(&cmd.inputPath, "input", "", "Path to take the input from. Must be in json format. Uses a small example if not set")
+	cobraCmd.Flags().IntVar(&cmd.passes, "passes", 10, "Amount of passes to hand out in the example")
+	cobraCmd.Flags().BoolVar(&cmd.exact, "exact", false, "Compute the exact probabilities instead of estimating them")
+	cobraCmd.Flags().IntVar(&cmd.runs, "runs", 1000000, "How many runs to estimate with")
+	cobraCmd.Flags().IntVar(&cmd.workers, "workers", 0, "How many runs to do in parallel. Defaults to the number of CPUs")
+	cobraCmd.Flags().Int64Var(&cmd.seed, "seed", 0, "Seed for the estimation. Random if not set")
+}
+
+func (c *probabilitiesCmd) Probabilities(cmd *cobra.Command, args []string) {
+	var problem *runner.Problem
+	var avail []runner.Availability
+	if c.inputPath != "" {
+		conf, err := readConfig(c.inputPath)
+		if err != nil {
+			cmd.PrintErrln(err)
+			return
+		}
+		problem = conf.Problem()
+		avail = conf.Availabilities()
+	} else {
+		if c.passes <= 0 {
+			cmd.PrintErrln("--passes cannot be 0")
+			return
+		}
+		problem, avail = exampleProblem(c.passes)
+	}
+
+	if c.exact {
+		probs, err := problem.Probabilities(avail)
+		if err != nil {
+			cmd.PrintErrf("Computation failed: %s\n", err)
+			return
+		}
+		cmd.Println("Computed the exact probabilities:")
+		for _, part := range problem.Partitions() {
+			cmd.Printf("Partition %s\n", part)
+			for _, u := range problem.Users(part) {
+				cmd.Printf("User %-10s probability of %7.3f%%\n", u, probs[u]*100)
+			}
+		}
+		return
+	}
+
+	seed := c.seed
+	if !cmd.Flags().Changed("seed") {
+		seed = rand.Int63()
+	}
+	result, err := simulate.Run(cmd.Context(), problem, avail, simulate.Options{
+		Runs:    c.runs,
+		Workers: c.workers,
+		Seed:    seed,
+	})
+	if err != nil {
+		cmd.PrintErrf("Run failed: %s\n", err)
+		return
+	}
+
+	cmd.Printf("Estimated the probabilities with %d runs and seed %d:\n", result.Runs, seed)
+	for _, part := range problem.Partitions() {
+		cmd.Printf("Partition %s\n", part)
+		for _, u := range problem.Users(part) {
+			prob := result.UserProbability(u)
+			// 95% confidence interval of the estimate.
+			margin := 1.96 * math.Sqrt(prob*(1-prob)/float64(result.Runs))
+			cmd.Printf("User %-10s probability of %7.3f%% ± %.3f%%\n", u, prob*100, margin*100)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/wchresta/passdraw/pkg/runner"
+)
+
+type recycleCmd struct {
+	inputPath    string
+	solutionPath string
+	waitlistPath string
+	outPath      string
+	changesPath  string
+	canceled     []string
+	policy       string
+}
+
+func init() {
+	cmd := recycleCmd{}
+
+	var cobraCmd = &cobra.Command{
+		Use:   "recycle",
+		Short: "Reassign the passes of canceled users to waitlisted users.",
+		Long: `Takes a previous solution, frees the passes of canceled users and promotes
+waitlisted users in waitlist order.
+
+A waitlisted user whose dependencies do not have a pass is only promoted together
+with these dependencies, if there is room in all their partitions.
+Writes the new solution and prints who gained and who lost a pass.`,
+		Run: cmd.Recycle,
+	}
+
+	rootCmd.AddCommand(cobraCmd)
+
+	cobraCmd.Flags().StringVar(&cmd.inputPath, "input", "", "Path to take the input from. Must be in json format")
+	cobraCmd.Flags().StringVar(&cmd.solutionPath, "solution", "", "Path to the previous solution")
+	cobraCmd.Flags().StringVar(&cmd.waitlistPath, "waitlist", "", "Path to a json waitlist by partition. Defaults to the waitlist of the previous solution")
+	cobraCmd.Flags().StringSliceVar(&cmd.canceled, "cancel", nil, "IDs of users that canceled their registration")
+	cobraCmd.Flags().StringVar(&cmd.policy, "dependees", string(runner.RefuseDependees), "What happens to users depending on a canceled user; `refuse` or `keep` their pass")
+	cobraCmd.Flags().StringVar(&cmd.outPath, "out", "", "Path to write the new solution to. Defaults to stdout")
+	cobraCmd.Flags().StringVar(&cmd.changesPath, "changes", "", "Path to write the change set to in json format")
+	cobraCmd.MarkFlagRequired("input")
+	cobraCmd.MarkFlagRequired("solution")
+}
+
+func (c *recycleCmd) Recycle(cmd *cobra.Command, args []string) {
+	conf, err := readConfig(c.inputPath)
+	if err != nil {
+		cmd.PrintErrln(err)
+		return
+	}
+
+	var prev runner.Solution
+	if err := readJSON(c.solutionPath, &prev); err != nil {
+		cmd.PrintErrln(err)
+		return
+	}
+	if c.waitlistPath != "" {
+		prev.Waitlist = nil
+		if err := readJSON(c.waitlistPath, &prev.Waitlist); err != nil {
+			cmd.PrintErrln(err)
+			return
+		}
+	}
+
+	var canceled []runner.UserID
+	for _, id := range c.canceled {
+		canceled = append(canceled, runner.UserID(id))
+	}
+
+	run := conf.Runner()
+	solution, changes, err := run.Recycle(conf.Availabilities(), &prev, canceled, runner.CancelPolicy(c.policy))
+	if err != nil {
+		cmd.PrintErrf("Recycle failed: %s\n", err)
+		return
+	}
+
+	for part, lost := range sortedKeys(changes.Lost) {
+		for _, u := range lost {
+			cmd.Printf("%s - lost pass: %s\n", part, u)
+		}
+	}
+	for part, gained := range sortedKeys(changes.Gained) {
+		for _, u := range gained {
+			cmd.Printf("%s - gained pass: %s\n", part, u)
+		}
+	}
+
+	if err := writeJSON(cmd, c.outPath, solution); err != nil {
+		cmd.PrintErrln(err)
+		return
+	}
+	if c.changesPath != "" {
+		if err := writeJSON(cmd, c.changesPath, changes); err != nil {
+			cmd.PrintErrln(err)
+		}
+	}
+}
@@ -6,12 +6,14 @@ package cmd
 import (
 	"fmt"
 	"maps"
+	"math/rand"
 	"os"
 	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/wchresta/passdraw/pkg/commit"
 	"github.com/wchresta/passdraw/pkg/input"
 	"github.com/wchresta/passdraw/pkg/runner"
 )
@@ -19,6 +21,41 @@ import (
 type runCmd struct {
 	availStrings []string
 	inputPath    string
+	seed         int64
+	fillUp       bool
+	strategy     string
+	dangling     string
+	output       string
+	outPath      string
+}
+
+// runReportVersion is the version of the json output of run.
+const runReportVersion = 1
+
+// runReport is the json output of run. It embeds the solution, so it can be
+// passed to all commands that take a solution.
+type runReport struct {
+	Version  int
+	Seed     int64
+	Strategy string
+	// InputHash is the hash of the canonical input, see commit.Hash.
+	InputHash  string `json:",omitempty"`
+	Partitions map[runner.Partition]runPartition
+	// Dangling lists, for every user depending on users that did not register, these users.
+	// DanglingPolicy decides what happened to them.
+	Dangling       map[runner.UserID][]runner.UserID `json:",omitempty"`
+	DanglingPolicy input.DanglingPolicy              `json:",omitempty"`
+	// Gained lists the users that got a pass in the fill-up phase, if enabled.
+	Gained map[runner.Partition][]runner.UserID `json:",omitempty"`
+	*runner.Solution
+}
+
+type runPartition struct {
+	Available int
+	Granted   []runner.UserID
+	// Refused lists the users that were refused for the partition, and did not get a pass for it later.
+	Refused  []runner.UserID
+	Waitlist []runner.UserID
 }
 
 func init() {
@@ -40,11 +77,25 @@ func init() {
 
 	cobraCmd.Flags().StringSliceVar(&cmd.availStrings, "passes", nil, "Specify availability of passes for partition; format `partition:passes` e.g. `leaders:33`")
 	cobraCmd.Flags().StringVar(&cmd.inputPath, "input", "", "Path to take the input from. Must be in json format")
+	cobraCmd.Flags().BoolVar(&cmd.fillUp, "fill-up", false, "Re-admit refused users in waitlist order to partitions that were left with unused passes")
+	cobraCmd.Flags().StringVar(&cmd.strategy, "strategy", runner.Backward.Name(), "Strategy to draw the passes with; one of backward, forward, ticket-order, tickets")
+	cobraCmd.Flags().StringVar(&cmd.dangling, "dangling", "", "What happens to users depending on users that did not register; one of fail, refuse, ignore. Overrides DanglingDeps of the input")
+	cobraCmd.Flags().Int64Var(&cmd.seed, "seed", 0, "Seed for the random draw. The same input and seed always give the same result. Random if not set")
+	cobraCmd.Flags().StringVar(&cmd.output, "output", "text", "Output format; one of text, json")
+	cobraCmd.Flags().StringVar(&cmd.outPath, "out-file", "", "Path to write the output to. Defaults to stdout")
 }
 
 func (c *runCmd) Run(cmd *cobra.Command, args []string) {
 	var run *runner.Runner
 	var avail []runner.Availability
+	var inputHash string
+	var dangling map[runner.UserID][]runner.UserID
+	var danglingPolicy input.DanglingPolicy
+
+	if c.output != "text" && c.output != "json" {
+		cmd.PrintErrf("unknown output format %q, must be one of text, json\n", c.output)
+		return
+	}
 
 	availMap, err := availMapFromAvailStrings(c.availStrings)
 	if err != nil {
@@ -53,20 +104,35 @@ func (c *runCmd) Run(cmd *cobra.Command, args []string) {
 	}
 	avail = slices.Collect(maps.Values(availMap))
 
+	strategy, err := runner.StrategyByName(c.strategy)
+	if err != nil {
+		cmd.PrintErrln(err)
+		return
+	}
+
+	seed := c.seed
+	if !cmd.Flags().Changed("seed") {
+		seed = rand.Int63()
+	}
+
 	if c.inputPath != "" {
-		inputBs, err := os.ReadFile(c.inputPath)
+		conf, err := readConfigWithPolicy(c.inputPath, input.DanglingPolicy(c.dangling))
 		if err != nil {
-			cmd.PrintErrf("cannot read input file %s: %s", c.inputPath, err)
+			cmd.PrintErrln(err)
 			return
 		}
 
-		conf, err := input.NewFromJSON(inputBs)
+		dangling = conf.Dangling()
+		danglingPolicy = conf.DanglingPolicy()
+
+		inputHash, err = commit.Hash(conf)
 		if err != nil {
-			cmd.PrintErrf("cannot parse input file %s: %s", c.inputPath, err)
+			cmd.PrintErrln(err)
 			return
 		}
 
-		run = conf.Runner()
+		run = conf.RunnerWithRand(rand.New(rand.NewSource(seed)))
+		run.SetStrategy(strategy)
 		if len(avail) == 0 {
 			avail = conf.Availabilities()
 			for _, a := range avail {
@@ -81,24 +147,119 @@ func (c *runCmd) Run(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	cmd.Println("Executed Run for the following availabilities:")
-	for partName, partPass := range solution.Passes {
+	var gained map[runner.Partition][]runner.UserID
+	if c.fillUp {
+		filled, changes, err := run.FillUp(avail, solution)
+		if err != nil {
+			cmd.PrintErrf("Fill-up failed: %s", err)
+			return
+		}
+		solution = filled
+		gained = changes.Gained
+	}
+
+	if c.output == "json" {
+		report := newRunReport(seed, strategy, inputHash, solution, availMap)
+		if len(dangling) > 0 {
+			report.Dangling = dangling
+			report.DanglingPolicy = danglingPolicy
+		}
+		report.Gained = gained
+		if err := writeJSON(cmd, c.outPath, report); err != nil {
+			cmd.PrintErrln(err)
+		}
+		return
+	}
+
+	if c.outPath != "" {
+		f, err := os.Create(c.outPath)
+		if err != nil {
+			cmd.PrintErrf("cannot write file %s: %s\n", c.outPath, err)
+			return
+		}
+		defer f.Close()
+		cmd.SetOut(f)
+	}
+
+	if len(dangling) > 0 {
+		cmd.Printf("The following %d users depend on users that did not register; dangling policy %s:\n", len(dangling), danglingPolicy)
+		for u, deps := range sortedKeys(dangling) {
+			cmd.Printf(" ! %s: %s\n", u, deps)
+		}
+	}
+
+	if c.fillUp {
+		recovered := 0
+		for _, partGained := range gained {
+			recovered += len(partGained)
+		}
+		cmd.Printf("Fill-up phase recovered %d passes:\n", recovered)
+		for part, partGained := range sortedKeys(gained) {
+			for _, u := range partGained {
+				cmd.Printf(" + %s: %s\n", part, u)
+			}
+		}
+	}
+
+	printSolution(cmd, seed, solution, availMap)
+
+	if strategy == runner.Tickets {
+		problem := run.Problem()
+		tickets := problem.Tickets(solution.TicketSeed)
+		cmd.Printf("Tickets derived from ticket seed %d; users with higher tickets are refused later:\n", solution.TicketSeed)
+		for _, part := range problem.Partitions() {
+			for _, u := range problem.Users(part) {
+				cmd.Printf(" # %s: %s %.9f\n", part, u, tickets[u])
+			}
+		}
+	}
+}
+
+// newRunReport collects the outcome of every partition of the solution.
+func newRunReport(seed int64, strategy runner.Strategy, inputHash string, solution *runner.Solution, availMap map[runner.Partition]runner.Availability) *runReport {
+	report := &runReport{
+		Version:    runReportVersion,
+		Seed:       seed,
+		Strategy:   strategy.Name(),
+		InputHash:  inputHash,
+		Partitions: make(map[runner.Partition]runPartition),
+		Solution:   solution,
+	}
+	for part, granted := range solution.Passes {
+		refused := []runner.UserID{}
+		for u, refusals := range sortedKeys(solution.Refusals) {
+			if slices.Contains(granted, u) {
+				continue
+			}
+			if slices.ContainsFunc(refusals, func(r runner.Refusal) bool { return r.Partition == part }) {
+				refused = append(refused, u)
+			}
+		}
+		report.Partitions[part] = runPartition{
+			Available: availMap[part].Available,
+			Granted:   slices.Sorted(slices.Values(granted)),
+			Refused:   refused,
+			Waitlist:  solution.Waitlist[part],
+		}
+	}
+	return report
+}
+
+// printSolution prints who got a pass and the waitlists of the solution.
+func printSolution(cmd *cobra.Command, seed int64, solution *runner.Solution, availMap map[runner.Partition]runner.Availability) {
+	cmd.Printf("Executed Run with seed %d for the following availabilities:\n", seed)
+	for partName, partPass := range sortedKeys(solution.Passes) {
 		a := availMap[partName]
 
 		cmd.Printf("%s - Handed out %d out of %d passes for partition:\n", partName, len(partPass), a.Available)
 		slices.Sort(partPass)
-
-		hasPass := make(map[runner.UserID]bool)
-		for _, u := range run.Users(partName) {
-			hasPass[u] = false
-		}
 		for _, pass := range partPass {
-			delete(hasPass, pass)
 			cmd.Println(" O " + pass)
 		}
 
-		cmd.Printf("%s - The following %d users did not get a pass:\n", partName, len(hasPass))
-		for u := range sortedKeys(hasPass) {
+		waitlist := solution.Waitlist[partName]
+		cmd.Printf("%s - The following %d users did not get a pass, in waitlist order:\n", partName, len(waitlist))
+		for _, u := range waitlist {
 			cmd.Println(" x " + u)
 		}
 	}
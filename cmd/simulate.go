@@ -8,15 +8,21 @@ import (
 	"fmt"
 	"iter"
 	"maps"
+	"math/rand"
 	"slices"
 
 	"github.com/spf13/cobra"
 	"github.com/wchresta/passdraw/pkg/runner"
+	"github.com/wchresta/passdraw/pkg/simulate"
 )
 
 type simulateCmd struct {
-	passes int
-	runs   int
+	inputPath  string
+	passes     int
+	runs       int
+	workers    int
+	seed       int64
+	strategies []string
 }
 
 func init() {
@@ -25,21 +31,134 @@ func init() {
 	var cobraCmd = &cobra.Command{
 		Use:   "simulate",
 		Short: "Do multiple runs and get statistics",
-		Run:   cmd.Simulate,
+		Long: `Simulates many draws of an input and reports, for every user, the probability
+to get a pass. For every partition, reports the expected amount of passes handed
+out, and how likely it is to be under-filled, i.e. to hand out fewer passes than
+available while users are left on the waitlist.
+
+Given multiple strategies, simulates each of them with the same seed and
+compares their results side by side.`,
+		Run: cmd.Simulate,
 	}
 
 	rootCmd.AddCommand(cobraCmd)
 
-	cobraCmd.Flags().IntVar(&cmd.passes, "passes", 10, "Amount of passes to hand out")
+	cobraCmd.Flags().StringVar(&cmd.inputPath, "input", "", "Path to take the input from. Must be in json format. Simulates a small example if not set")
+	cobraCmd.Flags().IntVar(&cmd.passes, "passes", 10, "Amount of passes to hand out in the example")
 	cobraCmd.Flags().IntVar(&cmd.runs, "runs", 1000000, "How many runs")
+	cobraCmd.Flags().IntVar(&cmd.workers, "workers", 0, "How many runs to do in parallel. Defaults to the number of CPUs")
+	cobraCmd.Flags().Int64Var(&cmd.seed, "seed", 0, "Seed for the simulation. Random if not set")
+	cobraCmd.Flags().StringSliceVar(&cmd.strategies, "strategy", []string{runner.Backward.Name()}, "Strategies to draw the passes with; any of backward, forward, ticket-order, tickets")
 }
 
 func (c *simulateCmd) Simulate(cmd *cobra.Command, args []string) {
-	if c.passes <= 0 {
-		cmd.PrintErrln("--passes cannot be 0")
+	var problem *runner.Problem
+	var avail []runner.Availability
+	if c.inputPath != "" {
+		conf, err := readConfig(c.inputPath)
+		if err != nil {
+			cmd.PrintErrln(err)
+			return
+		}
+		problem = conf.Problem()
+		avail = conf.Availabilities()
+	} else {
+		if c.passes <= 0 {
+			cmd.PrintErrln("--passes cannot be 0")
+			return
+		}
+		problem, avail = exampleProblem(c.passes)
+	}
+
+	var strategies []runner.Strategy
+	for _, name := range c.strategies {
+		strategy, err := runner.StrategyByName(name)
+		if err != nil {
+			cmd.PrintErrln(err)
+			return
+		}
+		strategies = append(strategies, strategy)
+	}
+
+	seed := c.seed
+	if !cmd.Flags().Changed("seed") {
+		seed = rand.Int63()
+	}
+
+	var results []*simulate.Result
+	for _, strategy := range strategies {
+		lastPercent := -1
+		result, err := simulate.Run(cmd.Context(), problem, avail, simulate.Options{
+			Runs:     c.runs,
+			Workers:  c.workers,
+			Seed:     seed,
+			Strategy: strategy,
+			Progress: func(done, total int) {
+				if percent := done * 100 / total; percent != lastPercent {
+					lastPercent = percent
+					cmd.PrintErrf("\rSimulated %d of %d runs with strategy %s (%d%%)", done, total, strategy.Name(), percent)
+				}
+			},
+		})
+		cmd.PrintErrln()
+		if err != nil {
+			cmd.PrintErrf("Run failed: %s", err)
+			return
+		}
+		results = append(results, result)
+	}
+
+	availMap := make(map[runner.Partition]int)
+	for _, a := range avail {
+		availMap[a.Partition] = a.Available
+	}
+
+	if len(results) == 1 {
+		result := results[0]
+		cmd.Printf("Performed %d runs with seed %d; here are the statistics:\n", result.Runs, seed)
+		for _, part := range problem.Partitions() {
+			users := problem.Users(part)
+			cmd.Printf("Handed out %d passes to %d users in partition %s\n", availMap[part], len(users), part)
+			for _, u := range users {
+				cmd.Printf("User %-10s got a total of %6d passes; probability of %4.1f%%\n", u, result.UserPasses(u), result.UserProbability(u)*100)
+			}
+			cmd.Printf("Expected to hand out %.1f passes for partition %s; under-filled in %4.1f%% of runs\n",
+				result.ExpectedHanded(part), part, result.UnderfilledProbability(part)*100)
+		}
 		return
 	}
 
+	cmd.Printf("Performed %d runs per strategy with seed %d; here are the probabilities:\n", c.runs, seed)
+	header := fmt.Sprintf("%-15s", "")
+	for _, strategy := range strategies {
+		header += fmt.Sprintf(" %13s", strategy.Name())
+	}
+	for _, part := range problem.Partitions() {
+		users := problem.Users(part)
+		cmd.Printf("Handed out %d passes to %d users in partition %s\n", availMap[part], len(users), part)
+		cmd.Println(header)
+		for _, u := range users {
+			line := fmt.Sprintf("User %-10s", u)
+			for _, result := range results {
+				line += fmt.Sprintf(" %12.1f%%", result.UserProbability(u)*100)
+			}
+			cmd.Println(line)
+		}
+		line := fmt.Sprintf("%-15s", "Expected passes")
+		for _, result := range results {
+			line += fmt.Sprintf(" %13.1f", result.ExpectedHanded(part))
+		}
+		cmd.Println(line)
+		line = fmt.Sprintf("%-15s", "Under-filled")
+		for _, result := range results {
+			line += fmt.Sprintf(" %12.1f%%", result.UnderfilledProbability(part)*100)
+		}
+		cmd.Println(line)
+	}
+}
+
+// exampleProblem returns a small example with various dependencies.
+func exampleProblem(passes int) (*runner.Problem, []runner.Availability) {
 	leaderP := runner.Partition("Leader")
 	followP := runner.Partition("Follow")
 	users := []runner.User{
@@ -70,45 +189,11 @@ func (c *simulateCmd) Simulate(cmd *cobra.Command, args []string) {
 		{Partition: leaderP, ID: "LC2", Deps: []runner.UserID{"FC2"}},
 		{Partition: followP, ID: "FC2", Deps: []runner.UserID{"LC2"}},
 	}
-	userCountByPart := make(map[runner.Partition]int)
-	for _, u := range users {
-		userCountByPart[u.Partition]++
-	}
-	r := runner.New(users)
-
-	availMap := make(map[runner.Partition]runner.Availability)
-	availMap[leaderP] = runner.Availability{Partition: leaderP, Available: c.passes / 2}
-	availMap[followP] = runner.Availability{Partition: followP, Available: c.passes - (c.passes / 2)}
-
-	passes := make(map[runner.Partition]map[runner.UserID]int)
-	for i := 0; i <= c.runs; i++ {
-		solution, err := r.Run(slices.Collect(maps.Values(availMap)))
-		if err != nil {
-			cmd.PrintErrf("Run failed: %s", err)
-			return
-		}
-
-		for part, partPass := range solution.Passes {
-			if _, ok := passes[part]; !ok {
-				passes[part] = make(map[runner.UserID]int)
-			}
-			for _, pass := range partPass {
-				passes[part][pass] += 1
-			}
-		}
-	}
-
-	cmd.Printf("Performed %d runs; here are the statistics:\n", c.runs)
-	for part, passes := range sortedKeys(passes) {
-		a := availMap[part]
-		fmt.Printf("Handed out %d passes to %d users in partition %s\n", a.Available, userCountByPart[part], part)
-		totalPasses := 0
-		for u, n := range sortedKeys(passes) {
-			totalPasses += n
-			cmd.Printf("User %-10s got a total of %6d passes; probability of %4.1f%%\n", u, n, float64(n)/float64(c.runs)*100)
-		}
-		fmt.Printf("Handed out a total of %d passes for partition %s\n", totalPasses, part)
+	avail := []runner.Availability{
+		{Partition: leaderP, Available: passes / 2},
+		{Partition: followP, Available: passes - (passes / 2)},
 	}
+	return runner.Compile(users), avail
 }
 
 func sortedKeys[Map ~map[K]V, K cmp.Ordered, V any](m Map) iter.Seq2[K, V] {
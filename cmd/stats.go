@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/wchresta/passdraw/pkg/runner"
+)
+
+type statsCmd struct {
+	inputPath string
+	minDepth  int
+	top       int
+}
+
+func init() {
+	cmd := statsCmd{}
+
+	var cobraCmd = &cobra.Command{
+		Use:   "stats",
+		Short: "Show statistics about an input.",
+		Long: `Shows, for every partition, how many users registered for how many passes,
+and analyzes the dependencies between users:
+
+  cycles   users that depend on each other, e.g. couples
+  chains   users behind long chains of dependencies; their odds are much lower
+  fan-in   users many other users depend on`,
+		Run: cmd.Stats,
+	}
+
+	rootCmd.AddCommand(cobraCmd)
+
+	cobraCmd.Flags().StringVar(&cmd.inputPath, "input", "", "Path to take the input from. Must be in json format")
+	cobraCmd.Flags().IntVar(&cmd.minDepth, "min-depth", 2, "List users behind chains of at least this many dependencies")
+	cobraCmd.Flags().IntVar(&cmd.top, "top", 10, "How many users with the highest fan-in to list")
+	cobraCmd.MarkFlagRequired("input")
+}
+
+func (c *statsCmd) Stats(cmd *cobra.Command, args []string) {
+	if c.minDepth < 0 {
+		cmd.PrintErrf("invalid --min-depth %d, must not be negative\n", c.minDepth)
+		return
+	}
+	if c.top < 0 {
+		cmd.PrintErrf("invalid --top %d, must not be negative\n", c.top)
+		return
+	}
+	conf, err := readConfig(c.inputPath)
+	if err != nil {
+		cmd.PrintErrln(err)
+		return
+	}
+	problem := conf.Problem()
+
+	availMap := make(map[runner.Partition]int)
+	for _, a := range conf.Availabilities() {
+		availMap[a.Partition] = a.Available
+	}
+	for _, part := range problem.Partitions() {
+		users := len(problem.Users(part))
+		cmd.Printf("%s - %d users registered for %d passes", part, users, availMap[part])
+		if users > 0 {
+			cmd.Printf("; %.1f users per pass", float64(users)/float64(max(availMap[part], 1)))
+		}
+		cmd.Println()
+	}
+
+	graph := problem.Analyze()
+	edges := 0
+	for _, n := range graph.FanIn {
+		edges += n
+	}
+	cmd.Printf("Dependency graph of %d users with %d dependencies:\n", len(graph.FanIn), edges)
+
+	cmd.Printf("Cycles - %d groups of users depend on each other:\n", len(graph.Components))
+	for _, component := range graph.Components {
+		cmd.Printf(" @ %d users: %s\n", len(component), joinIDs(component))
+	}
+
+	deep := slices.DeleteFunc(slices.Collect(maps.Keys(graph.Depth)), func(u runner.UserID) bool {
+		return graph.Depth[u] < c.minDepth
+	})
+	slices.SortFunc(deep, func(a, b runner.UserID) int {
+		return cmp.Or(cmp.Compare(graph.Depth[b], graph.Depth[a]), cmp.Compare(a, b))
+	})
+	maxDepth := 0
+	for _, d := range graph.Depth {
+		maxDepth = max(maxDepth, d)
+	}
+	cmd.Printf("Chains - the longest chain has %d dependencies; %d users are behind chains of at least %d:\n", maxDepth, len(deep), c.minDepth)
+	for _, u := range deep {
+		cmd.Printf(" > %s: %d\n", u, graph.Depth[u])
+	}
+
+	popular := slices.DeleteFunc(slices.Collect(maps.Keys(graph.FanIn)), func(u runner.UserID) bool {
+		return graph.FanIn[u] == 0
+	})
+	slices.SortFunc(popular, func(a, b runner.UserID) int {
+		return cmp.Or(cmp.Compare(graph.FanIn[b], graph.FanIn[a]), cmp.Compare(a, b))
+	})
+	popular = popular[:min(c.top, len(popular))]
+	cmd.Printf("Fan-in - the %d users most depended on:\n", len(popular))
+	for _, u := range popular {
+		cmd.Printf(" < %s: %d dependees\n", u, graph.FanIn[u])
+	}
+}
+
+func joinIDs(ids []runner.UserID) string {
+	s := ""
+	for i, id := range ids {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprint(id)
+	}
+	return s
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/wchresta/passdraw/pkg/input"
+)
+
+type validateCmd struct {
+	inputPath string
+	output    string
+}
+
+// validationReport is the json output of the validate command.
+type validationReport struct {
+	Valid  bool
+	Errors input.ValidationErrors
+}
+
+func init() {
+	cmd := validateCmd{}
+
+	var cobraCmd = &cobra.Command{
+		Use:   "validate",
+		Short: "Check an input for mistakes.",
+		Long: `Checks an input and lists all problems found, each with the json path of the
+offending value, e.g. duplicate user IDs, dependencies on unknown users or users
+depending on themselves.
+
+Exits with status 1 if the input is invalid.`,
+		Run: cmd.Validate,
+	}
+
+	rootCmd.AddCommand(cobraCmd)
+
+	cobraCmd.Flags().StringVar(&cmd.inputPath, "input", "", "Path to take the input from. Must be in json format")
+	cobraCmd.Flags().StringVar(&cmd.output, "output", "text", "Output format; one of text, json")
+	cobraCmd.MarkFlagRequired("input")
+}
+
+func (c *validateCmd) Validate(cmd *cobra.Command, args []string) {
+	if c.output != "text" && c.output != "json" {
+		cmd.PrintErrf("unknown output format %q, must be one of text, json\n", c.output)
+		return
+	}
+
+	inputBs, err := os.ReadFile(c.inputPath)
+	if err != nil {
+		cmd.PrintErrf("cannot read input file %s: %s\n", c.inputPath, err)
+		return
+	}
+
+	var errs input.ValidationErrors
+	conf, err := input.NewFromJSON(inputBs)
+	if err != nil && !errors.As(err, &errs) {
+		cmd.PrintErrf("cannot parse input file %s: %s\n", c.inputPath, err)
+		return
+	}
+
+	if c.output == "json" {
+		report := validationReport{Valid: len(errs) == 0, Errors: errs}
+		if report.Errors == nil {
+			report.Errors = input.ValidationErrors{}
+		}
+		if err := writeJSON(cmd, "", report); err != nil {
+			cmd.PrintErrln(err)
+			return
+		}
+	} else if len(errs) == 0 {
+		users := 0
+		for _, partUsers := range conf.Users {
+			users += len(partUsers)
+		}
+		cmd.Printf("Input %s is valid: %d users in %d partitions\n", c.inputPath, users, len(conf.Users))
+	} else {
+		cmd.Printf("Input %s has %d problems:\n", c.inputPath, len(errs))
+		for _, e := range errs {
+			cmd.Printf(" x %s: %s\n", e.Path, e.Message)
+		}
+	}
+
+	if len(errs) > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"os"
+	"slices"
+
+	"github.com/spf13/cobra"
+	"github.com/wchresta/passdraw/pkg/runner"
+)
+
+type verifyCmd struct {
+	inputPath      string
+	commitmentPath string
+	beaconPath     string
+	solutionPath   string
+}
+
+func init() {
+	cmd := verifyCmd{}
+
+	var cobraCmd = &cobra.Command{
+		Use:   "verify",
+		Short: "Verify a published solution against a commitment and a beacon.",
+		Long: `Recomputes the draw from the input, the commitment and the beacon, and checks
+that the published solution matches. Exits with a non-zero code if it does not.`,
+		Run: cmd.Verify,
+	}
+
+	rootCmd.AddCommand(cobraCmd)
+
+	cobraCmd.Flags().StringVar(&cmd.inputPath, "input", "", "Path to take the input from. Must be in json format")
+	cobraCmd.Flags().StringVar(&cmd.commitmentPath, "commitment", "", "Path to the commitment written by `passdraw commit`")
+	cobraCmd.Flags().StringVar(&cmd.beaconPath, "beacon", "", "Path to a file containing the published beacon value")
+	cobraCmd.Flags().StringVar(&cmd.solutionPath, "solution", "", "Path to the published solution written by `passdraw draw`")
+	cobraCmd.MarkFlagRequired("input")
+	cobraCmd.MarkFlagRequired("commitment")
+	cobraCmd.MarkFlagRequired("beacon")
+	cobraCmd.MarkFlagRequired("solution")
+}
+
+func (c *verifyCmd) Verify(cmd *cobra.Command, args []string) {
+	conf, err := readConfig(c.inputPath)
+	if err != nil {
+		cmd.PrintErrln(err)
+		os.Exit(1)
+	}
+
+	var published runner.Solution
+	if err := readJSON(c.solutionPath, &published); err != nil {
+		cmd.PrintErrln(err)
+		os.Exit(1)
+	}
+
+	want, seed, err := drawCommitted(conf, c.commitmentPath, c.beaconPath)
+	if err != nil {
+		cmd.PrintErrln(err)
+		os.Exit(1)
+	}
+
+	ok := true
+	for part, wantPass := range sortedKeys(want.Passes) {
+		gotPass := published.Passes[part]
+		for _, u := range wantPass {
+			if !slices.Contains(gotPass, u) {
+				ok = false
+				cmd.Printf("%s - %s should have a pass but does not\n", part, u)
+			}
+		}
+		for _, u := range gotPass {
+			if !slices.Contains(wantPass, u) {
+				ok = false
+				cmd.Printf("%s - %s has a pass but should not\n", part, u)
+			}
+		}
+	}
+	if published.Waitlist != nil {
+		for part, wantWaitlist := range sortedKeys(want.Waitlist) {
+			if !slices.Equal(published.Waitlist[part], wantWaitlist) {
+				ok = false
+				cmd.Printf("%s - waitlist does not match\n", part)
+			}
+		}
+	}
+	for part := range sortedKeys(published.Passes) {
+		if _, found := want.Passes[part]; !found {
+			ok = false
+			cmd.Printf("%s - partition is not part of the input\n", part)
+		}
+	}
+
+	if !ok {
+		cmd.PrintErrf("Verification FAILED for seed %d\n", seed)
+		os.Exit(1)
+	}
+	cmd.Printf("Verification succeeded for seed %d\n", seed)
+}
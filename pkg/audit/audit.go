@@ -0,0 +1,287 @@
+// Package audit checks the fairness properties of the README for a concrete input.
+//
+// The properties are checked on probabilities estimated by simulation.
+// Every check is a statistical test; a user violates a property if the test rejects
+// it at the given significance level, corrected for the amount of users tested.
+package audit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/wchresta/passdraw/pkg/runner"
+	"github.com/wchresta/passdraw/pkg/simulate"
+)
+
+type Property string
+
+const (
+	// MinimumProbability: users who do not define any constraints have at least
+	// probability n_t / m_t to get a pass.
+	MinimumProbability Property = "minimum-probability"
+	// EqualTreatment: two users with the same constraints have the same probability
+	// to get a pass.
+	EqualTreatment Property = "equal-treatment"
+	// DependencyMonotonicity: the probability of a user to get a pass is never
+	// lowered by other users adding dependencies.
+	DependencyMonotonicity Property = "dependency-monotonicity"
+)
+
+// Properties lists all properties in the order they are checked.
+var Properties = []Property{MinimumProbability, EqualTreatment, DependencyMonotonicity}
+
+type Options struct {
+	simulate.Options
+	// Alpha is the significance level of each property; defaults to 0.01.
+	Alpha float64
+}
+
+type Violation struct {
+	Property Property
+	User     runner.UserID
+	// Estimated is the estimated probability of the user to get a pass.
+	Estimated float64
+	// Expected is the probability the property demands.
+	Expected float64
+	// Gap is by how much the estimated probability falls short of the expected one.
+	Gap    float64
+	PValue float64
+}
+
+type Report struct {
+	Runs int
+	// Checked counts the users each property was checked for.
+	Checked    map[Property]int
+	Violations []Violation
+}
+
+// Audit simulates draws of the users and checks all properties.
+func Audit(ctx context.Context, users []runner.User, availabilities []runner.Availability, opts Options) (*Report, error) {
+	if opts.Alpha <= 0 {
+		opts.Alpha = 0.01
+	}
+
+	problem := runner.Compile(users)
+	result, err := simulate.Run(ctx, problem, availabilities, opts.Options)
+	if err != nil {
+		return nil, err
+	}
+
+	// The baseline is the same input without any dependencies.
+	baselineUsers := problem.AllUsers()
+	for i := range baselineUsers {
+		baselineUsers[i].Deps = nil
+		baselineUsers[i].DepGroups = nil
+		baselineUsers[i].Group = ""
+	}
+	baselineOpts := opts.Options
+	baselineOpts.Seed = ^opts.Seed
+	baselineOpts.Progress = nil
+	baseline, err := simulate.Run(ctx, runner.Compile(baselineUsers), availabilities, baselineOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	a := auditor{
+		users:     problem.AllUsers(),
+		available: make(map[runner.Partition]int),
+		result:    result,
+		baseline:  baseline,
+		alpha:     opts.Alpha,
+		report: &Report{
+			Runs:    result.Runs,
+			Checked: make(map[Property]int),
+		},
+	}
+	for _, av := range availabilities {
+		a.available[av.Partition] = av.Available
+	}
+	a.minimumProbability()
+	a.equalTreatment()
+	a.dependencyMonotonicity()
+	return a.report, nil
+}
+
+type auditor struct {
+	users     []runner.User
+	available map[runner.Partition]int
+	result    *simulate.Result
+	baseline  *simulate.Result
+	alpha     float64
+	report    *Report
+}
+
+// unconstrained returns true if the user defines no constraints at all.
+func unconstrained(u runner.User) bool {
+	return len(u.Deps) == 0 && len(u.DepGroups) == 0 && u.Group == "" &&
+		len(u.Fallbacks) == 0 && u.State == runner.Drawn && (u.Weight <= 0 || u.Weight == 1)
+}
+
+// hasNoDependencies returns true if the user does not depend on anybody.
+func hasNoDependencies(u runner.User) bool {
+	return len(u.Deps) == 0 && len(u.DepGroups) == 0 && u.Group == ""
+}
+
+func (a *auditor) minimumProbability() {
+	// n_t and m_t do not count users that are not part of the draw.
+	passes := make(map[runner.Partition]int)
+	drawn := make(map[runner.Partition]int)
+	for part, n := range a.available {
+		passes[part] = n
+	}
+	for _, u := range a.users {
+		switch u.State {
+		case runner.Guaranteed:
+			passes[u.Partition]--
+		case runner.Drawn:
+			drawn[u.Partition]++
+		}
+	}
+
+	var tests []test
+	for _, u := range a.users {
+		if !unconstrained(u) {
+			continue
+		}
+		expected := math.Min(1, float64(passes[u.Partition])/float64(drawn[u.Partition]))
+		estimated := a.result.UserProbability(u.ID)
+		tests = append(tests, test{
+			user:      u.ID,
+			estimated: estimated,
+			expected:  expected,
+			pValue:    oneSidedPValue(estimated, expected, binomialVariance(expected)/float64(a.result.Runs)),
+		})
+	}
+	a.record(MinimumProbability, tests)
+}
+
+func (a *auditor) equalTreatment() {
+	classes := make(map[string][]runner.User)
+	for _, u := range a.users {
+		sig := signature(u)
+		classes[sig] = append(classes[sig], u)
+	}
+
+	var tests []test
+	for _, class := range classes {
+		if len(class) < 2 {
+			continue
+		}
+		total := 0
+		for _, u := range class {
+			total += a.result.UserPasses(u.ID)
+		}
+		for _, u := range class {
+			// Compare every user with all other users of the class.
+			n := float64(a.result.Runs)
+			own := a.result.UserProbability(u.ID)
+			others := float64(total-a.result.UserPasses(u.ID)) / (n * float64(len(class)-1))
+			pooled := float64(total) / (n * float64(len(class)))
+			variance := binomialVariance(pooled) * (1/n + 1/(n*float64(len(class)-1)))
+			tests = append(tests, test{
+				user:      u.ID,
+				estimated: own,
+				expected:  others,
+				pValue:    twoSidedPValue(own, others, variance),
+			})
+		}
+	}
+	a.record(EqualTreatment, tests)
+}
+
+func (a *auditor) dependencyMonotonicity() {
+	n := float64(a.result.Runs)
+	var tests []test
+	for _, u := range a.users {
+		if !hasNoDependencies(u) || u.State != runner.Drawn {
+			continue
+		}
+		estimated := a.result.UserProbability(u.ID)
+		expected := a.baseline.UserProbability(u.ID)
+		variance := binomialVariance(estimated)/n + binomialVariance(expected)/n
+		tests = append(tests, test{
+			user:      u.ID,
+			estimated: estimated,
+			expected:  expected,
+			pValue:    oneSidedPValue(estimated, expected, variance),
+		})
+	}
+	a.record(DependencyMonotonicity, tests)
+}
+
+type test struct {
+	user      runner.UserID
+	estimated float64
+	expected  float64
+	pValue    float64
+}
+
+// record adds violations for all rejected tests, using a Bonferroni correction.
+func (a *auditor) record(prop Property, tests []test) {
+	a.report.Checked[prop] = len(tests)
+	slices.SortFunc(tests, func(x, y test) int {
+		return strings.Compare(string(x.user), string(y.user))
+	})
+	for _, t := range tests {
+		if t.pValue < a.alpha/float64(len(tests)) {
+			a.report.Violations = append(a.report.Violations, Violation{
+				Property:  prop,
+				User:      t.user,
+				Estimated: t.estimated,
+				Expected:  t.expected,
+				Gap:       t.expected - t.estimated,
+				PValue:    t.pValue,
+			})
+		}
+	}
+}
+
+// signature describes all constraints of a user.
+func signature(u runner.User) string {
+	deps := slices.Sorted(slices.Values(u.Deps))
+	groups := make([]string, 0, len(u.DepGroups))
+	for _, g := range u.DepGroups {
+		groups = append(groups, fmt.Sprintf("%d:%v", g.Required(), slices.Sorted(slices.Values(g.Users))))
+	}
+	slices.Sort(groups)
+	weight := u.Weight
+	if weight <= 0 {
+		weight = 1
+	}
+	return fmt.Sprintf("%s|%v|%v|%v|%s|%s|%g", u.Partition, u.Fallbacks, deps, groups, u.Group, u.State, weight)
+}
+
+func binomialVariance(p float64) float64 {
+	return p * (1 - p)
+}
+
+// oneSidedPValue returns the p-value of observing estimated, if the true probability
+// was at least expected.
+func oneSidedPValue(estimated, expected, variance float64) float64 {
+	if variance == 0 {
+		if estimated < expected {
+			return 0
+		}
+		return 1
+	}
+	return normalCDF((estimated - expected) / math.Sqrt(variance))
+}
+
+// twoSidedPValue returns the p-value of observing estimated, if the true probability
+// was expected.
+func twoSidedPValue(estimated, expected, variance float64) float64 {
+	if variance == 0 {
+		if estimated != expected {
+			return 0
+		}
+		return 1
+	}
+	return 2 * normalCDF(-math.Abs(estimated-expected)/math.Sqrt(variance))
+}
+
+func normalCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
@@ -0,0 +1,71 @@
+package audit_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/wchresta/passdraw/pkg/audit"
+	"github.com/wchresta/passdraw/pkg/runner"
+	"github.com/wchresta/passdraw/pkg/simulate"
+)
+
+func TestAudit(t *testing.T) {
+	mkUsers := func(favoredWeight float64) []runner.User {
+		var users []runner.User
+		for i := range 20 {
+			users = append(users, runner.User{Partition: "Left", ID: runner.UserID(fmt.Sprintf("L%02d", i))})
+			users = append(users, runner.User{Partition: "Right", ID: runner.UserID(fmt.Sprintf("R%02d", i))})
+		}
+		for i := range 5 {
+			users = append(users,
+				runner.User{Partition: "Left", ID: runner.UserID(fmt.Sprintf("CoupleL%d", i)), Deps: []runner.UserID{runner.UserID(fmt.Sprintf("CoupleR%d", i))}},
+				runner.User{Partition: "Right", ID: runner.UserID(fmt.Sprintf("CoupleR%d", i)), Deps: []runner.UserID{runner.UserID(fmt.Sprintf("CoupleL%d", i))}},
+			)
+		}
+		for i := range 5 {
+			users = append(users, runner.User{Partition: "Left", ID: runner.UserID(fmt.Sprintf("Favored%d", i)), Weight: favoredWeight})
+		}
+		return users
+	}
+	availability := []runner.Availability{
+		{Partition: "Left", Available: 15},
+		{Partition: "Right", Available: 15},
+	}
+	opts := audit.Options{Options: simulate.Options{Runs: 20000, Seed: 5544332211}}
+
+	t.Run("fair input", func(t *testing.T) {
+		report, err := audit.Audit(context.Background(), mkUsers(1), availability, opts)
+		if err != nil {
+			t.Fatalf("Audit failed unexpectedly: %s", err)
+		}
+		for _, prop := range audit.Properties {
+			if report.Checked[prop] == 0 {
+				t.Errorf("Audit did not check property %s", prop)
+			}
+		}
+		for _, v := range report.Violations {
+			t.Errorf("Audit found unexpected violation: %+v", v)
+		}
+	})
+
+	t.Run("heavily weighted users", func(t *testing.T) {
+		// Heavily weighted users take passes from the unconstrained users.
+		report, err := audit.Audit(context.Background(), mkUsers(100), availability, opts)
+		if err != nil {
+			t.Fatalf("Audit failed unexpectedly: %s", err)
+		}
+		found := false
+		for _, v := range report.Violations {
+			if v.Property == audit.MinimumProbability && v.User[0] == 'L' {
+				found = true
+				if v.Gap <= 0 {
+					t.Errorf("Violation has non-positive gap: %+v", v)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("Audit did not find violations of %s: %+v", audit.MinimumProbability, report.Violations)
+		}
+	})
+}
@@ -0,0 +1,77 @@
+// Package commit implements a commit-reveal scheme for publicly verifiable draws.
+//
+// Before registration closes, the organizer publishes a commitment to the input.
+// The seed of the draw is then derived from the committed input and a beacon value
+// that nobody can predict at commitment time, e.g. a lottery result. Anyone holding
+// the input and the beacon can recompute the draw and verify the published solution.
+package commit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/wchresta/passdraw/pkg/input"
+)
+
+// Version of the commitment format.
+const Version = 1
+
+type Commitment struct {
+	Version   int
+	InputHash string
+}
+
+// New creates a commitment for the given input.
+func New(conf *input.RunConfig) (*Commitment, error) {
+	h, err := Hash(conf)
+	if err != nil {
+		return nil, err
+	}
+	return &Commitment{
+		Version:   Version,
+		InputHash: h,
+	}, nil
+}
+
+// Hash returns the hex encoded SHA-256 hash of the canonical form of the input.
+func Hash(conf *input.RunConfig) (string, error) {
+	b, err := conf.Canonical()
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Check returns an error if the commitment does not match the given input.
+func (c *Commitment) Check(conf *input.RunConfig) error {
+	if c.Version != Version {
+		return fmt.Errorf("unsupported commitment version %d, want %d", c.Version, Version)
+	}
+	h, err := Hash(conf)
+	if err != nil {
+		return err
+	}
+	if h != c.InputHash {
+		return fmt.Errorf("input hash %s does not match committed hash %s", h, c.InputHash)
+	}
+	return nil
+}
+
+// Seed derives the seed of the draw from the committed input and the beacon.
+// Leading and trailing whitespace of the beacon is ignored.
+func (c *Commitment) Seed(beacon []byte) (int64, error) {
+	beacon = bytes.TrimSpace(beacon)
+	if len(beacon) == 0 {
+		return 0, fmt.Errorf("beacon cannot be empty")
+	}
+
+	h := sha256.New()
+	h.Write([]byte(c.InputHash))
+	h.Write([]byte{0})
+	h.Write(beacon)
+	return int64(binary.BigEndian.Uint64(h.Sum(nil))), nil
+}
@@ -0,0 +1,71 @@
+package commit_test
+
+import (
+	"testing"
+
+	"github.com/wchresta/passdraw/pkg/commit"
+	"github.com/wchresta/passdraw/pkg/input"
+)
+
+func TestHash_IgnoresOrder(t *testing.T) {
+	a, err := input.NewFromJSON([]byte(`{
+		"Passes": {"Leader": 1, "Follow": 1},
+		"Users": {
+			"Leader": [{"ID": "L1", "Deps": ["F1", "F2"]}, {"ID": "L2"}],
+			"Follow": [{"ID": "F1"}, {"ID": "F2"}]
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := input.NewFromJSON([]byte(`{
+		"Users": {
+			"Follow": [{"ID": "F2"}, {"ID": "F1"}],
+			"Leader": [{"ID": "L2"}, {"ID": "L1", "Deps": ["F2", "F1"]}]
+		},
+		"Passes": {"Follow": 1, "Leader": 1}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := commit.New(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ca.Check(b); err != nil {
+		t.Errorf("Check failed for reordered input: %s", err)
+	}
+
+	b.Passes["Leader"] = 2
+	if err := ca.Check(b); err == nil {
+		t.Errorf("Check succeeded for changed input")
+	}
+}
+
+func TestSeed(t *testing.T) {
+	c := commit.Commitment{Version: commit.Version, InputHash: "abc"}
+	s1, err := c.Seed([]byte("beacon\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2, err := c.Seed([]byte("  beacon"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s1 != s2 {
+		t.Errorf("Seed depends on surrounding whitespace: %d != %d", s1, s2)
+	}
+
+	s3, err := c.Seed([]byte("other beacon"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s1 == s3 {
+		t.Errorf("Seed does not depend on beacon")
+	}
+
+	if _, err := c.Seed([]byte(" \n")); err == nil {
+		t.Errorf("Seed accepted an empty beacon")
+	}
+}
@@ -2,8 +2,10 @@
 package input
 
 import (
+	"cmp"
 	"encoding/json"
-	"fmt"
+	"math/rand"
+	"slices"
 
 	"github.com/wchresta/passdraw/pkg/runner"
 )
@@ -12,70 +14,269 @@ type User struct {
 	ID   runner.UserID
 	Deps []runner.UserID `json:",omitempty"`
 
+	// DepGroups allow any-of and k-of-n dependencies.
+	// E.g. {"Users": ["A", "B", "C"], "Min": 2} means at least two of A, B and C must get a pass.
+	DepGroups []runner.DepGroup `json:",omitempty"`
+
+	// Fallbacks lists other partitions, in order of preference, the user would
+	// take a pass for if refused for the partition they are registered in.
+	Fallbacks []runner.Partition `json:",omitempty"`
+
+	// State is either empty, "guaranteed" or "excluded".
+	// Guaranteed users always get a pass, excluded users never do.
+	State runner.State `json:",omitempty"`
+
 	// Weight can change how likely it is for a user to get a pass.
 	// A number below 1 reduces changes to get a pass, number above 1 increase them.
-	// If 0; defaults to 1.
-	// Set to a number below 0 to guarantee a refusal.
+	// If 0 or below; defaults to 1.
+	// Use State to guarantee a pass or a refusal.
 	Weight float64 `json:",omitempty"`
 }
 
+// Group is a group of users that is either admitted or refused as a whole.
+// The members of a group can be registered in different partitions.
+type Group struct {
+	ID      runner.GroupID
+	Members []runner.UserID
+}
+
 type RunConfig struct {
 	Passes map[runner.Partition]int
 	Users  map[runner.Partition][]User
+	Groups []Group `json:",omitempty"`
+
+	// Ratios let two partitions share passes, e.g. to balance leaders and followers.
+	// The Passes of both partitions act as upper bounds.
+	Ratios []runner.Ratio `json:",omitempty"`
+
+	// DanglingDeps decides what happens to users depending on users that did not register.
+	// Defaults to DanglingFail.
+	DanglingDeps DanglingPolicy `json:",omitempty"`
 }
 
+// DanglingPolicy decides what happens to users with dependencies on users that did not register.
+type DanglingPolicy string
+
+const (
+	// DanglingFail makes dependencies on unknown users a validation error.
+	DanglingFail DanglingPolicy = "fail"
+	// DanglingRefuse refuses users whose dependencies cannot be satisfied because of unknown users.
+	// They are refused before the draw, like excluded users.
+	DanglingRefuse DanglingPolicy = "refuse"
+	// DanglingIgnore drops dependencies on unknown users.
+	DanglingIgnore DanglingPolicy = "ignore"
+)
+
+// NewFromJSON parses and validates a config.
+// If the config is invalid, the error is a ValidationErrors listing all problems.
 func NewFromJSON(b []byte) (*RunConfig, error) {
-	conf := RunConfig{}
-	if err := json.Unmarshal(b, &conf); err != nil {
+	conf, err := Parse(b)
+	if err != nil {
 		return nil, err
 	}
-	if err := conf.validate(); err != nil {
-		return nil, err
+	if errs := conf.Validate(); errs != nil {
+		return nil, errs
 	}
-	return &conf, nil
+	return conf, nil
 }
 
-func (r *RunConfig) validate() error {
-	seenPartitions := make(map[runner.Partition]bool)
-	for part := range r.Users {
-		if _, ok := seenPartitions[part]; ok {
-			return fmt.Errorf("format error: partition %s is listed in users multiple times", part)
-		}
-		seenPartitions[part] = false
+// Parse parses a config without validating it.
+func Parse(b []byte) (*RunConfig, error) {
+	conf := RunConfig{}
+	if err := json.Unmarshal(b, &conf); err != nil {
+		return nil, parseErrors(err)
 	}
+	return &conf, nil
+}
 
-	for part := range r.Passes {
-		if _, ok := seenPartitions[part]; !ok {
-			return fmt.Errorf("value error: found partition %s with passes but no users", part)
-		}
-		seenPartitions[part] = true
+// Canonical returns a canonical json encoding of the config.
+// Configs that only differ in the order of users or dependencies have the same encoding.
+func (r *RunConfig) Canonical() ([]byte, error) {
+	canon := RunConfig{
+		Passes:       r.Passes,
+		Users:        make(map[runner.Partition][]User),
+		DanglingDeps: r.DanglingDeps,
 	}
-
-	for part, seen := range seenPartitions {
-		if !seen {
-			return fmt.Errorf("value error: found partition %s with users but no passes", part)
+	for _, g := range r.Groups {
+		g.Members = slices.Clone(g.Members)
+		slices.Sort(g.Members)
+		canon.Groups = append(canon.Groups, g)
+	}
+	slices.SortFunc(canon.Groups, func(a, b Group) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	canon.Ratios = slices.SortedFunc(slices.Values(r.Ratios), func(a, b runner.Ratio) int {
+		return cmp.Compare(a.Left, b.Left)
+	})
+	for part, partUsers := range r.Users {
+		users := make([]User, 0, len(partUsers))
+		for _, u := range partUsers {
+			u.Deps = slices.Clone(u.Deps)
+			slices.Sort(u.Deps)
+			u.DepGroups = slices.Clone(u.DepGroups)
+			for i, g := range u.DepGroups {
+				g.Users = slices.Clone(g.Users)
+				slices.Sort(g.Users)
+				u.DepGroups[i] = g
+			}
+			slices.SortFunc(u.DepGroups, func(a, b runner.DepGroup) int {
+				return cmp.Or(
+					slices.Compare(a.Users, b.Users),
+					cmp.Compare(a.Min, b.Min),
+				)
+			})
+			users = append(users, u)
 		}
+		slices.SortFunc(users, func(a, b User) int {
+			return cmp.Compare(a.ID, b.ID)
+		})
+		canon.Users[part] = users
 	}
-
-	return nil
+	// encoding/json sorts map keys.
+	return json.Marshal(canon)
 }
 
+// Runner creates a runner with a random seed.
 func (r *RunConfig) Runner() *runner.Runner {
+	return runner.New(r.users())
+}
+
+// RunnerWithRand creates a runner that draws from the given rand.
+// Using a seeded rand makes the runner reproducible.
+func (r *RunConfig) RunnerWithRand(rand *rand.Rand) *runner.Runner {
+	return runner.NewWithRand(r.users(), rand)
+}
+
+// Problem compiles the users of the config into a problem that can be drawn from concurrently.
+func (r *RunConfig) Problem() *runner.Problem {
+	return runner.Compile(r.users())
+}
+
+func (r *RunConfig) users() []runner.User {
+	groupOf := make(map[runner.UserID]runner.GroupID)
+	for _, g := range r.Groups {
+		for _, m := range g.Members {
+			groupOf[m] = g.ID
+		}
+	}
+	known := r.known()
+
 	var users []runner.User
 	for part, partUsers := range r.Users {
 		partition := runner.Partition(part)
 		for _, u := range partUsers {
-			users = append(users, runner.User{
+			user := runner.User{
 				Partition: partition,
 				ID:        u.ID,
 				Deps:      u.Deps,
+				DepGroups: u.DepGroups,
+				Group:     groupOf[u.ID],
+				Fallbacks: u.Fallbacks,
+				State:     u.State,
 				Weight:    u.Weight,
-			})
+			}
+			switch r.DanglingPolicy() {
+			case DanglingRefuse:
+				if !satisfiable(user, known) && user.State != runner.Guaranteed {
+					user.State = runner.Excluded
+				}
+			case DanglingIgnore:
+				user.Deps, user.DepGroups = knownDeps(user, known)
+			}
+			users = append(users, user)
+		}
+	}
+	return users
+}
+
+// DanglingPolicy returns the policy for dependencies on users that did not register.
+func (r *RunConfig) DanglingPolicy() DanglingPolicy {
+	if r.DanglingDeps == "" {
+		return DanglingFail
+	}
+	return r.DanglingDeps
+}
+
+// Dangling returns, for every user with dependencies on users that did not register,
+// these unknown users.
+func (r *RunConfig) Dangling() map[runner.UserID][]runner.UserID {
+	known := r.known()
+	dangling := make(map[runner.UserID][]runner.UserID)
+	for _, partUsers := range r.Users {
+		for _, u := range partUsers {
+			deps := slices.Clone(u.Deps)
+			for _, g := range u.DepGroups {
+				deps = append(deps, g.Users...)
+			}
+			for _, dep := range deps {
+				if !known[dep] && !slices.Contains(dangling[u.ID], dep) {
+					dangling[u.ID] = append(dangling[u.ID], dep)
+				}
+			}
+		}
+	}
+	return dangling
+}
+
+func (r *RunConfig) known() map[runner.UserID]bool {
+	known := make(map[runner.UserID]bool)
+	for _, partUsers := range r.Users {
+		for _, u := range partUsers {
+			known[u.ID] = true
+		}
+	}
+	return known
+}
+
+// satisfiable returns false if the dependencies of the user cannot be satisfied
+// because of unknown users.
+func satisfiable(u runner.User, known map[runner.UserID]bool) bool {
+	for _, dep := range u.Deps {
+		if !known[dep] {
+			return false
 		}
 	}
-	return runner.New(users)
+	for _, g := range u.DepGroups {
+		possible := 0
+		for _, dep := range g.Users {
+			if known[dep] {
+				possible++
+			}
+		}
+		if possible < g.Required() {
+			return false
+		}
+	}
+	return true
 }
 
+// knownDeps returns the dependencies of the user without unknown users.
+// Dependency groups need at most all of their remaining users, and are dropped if none remain.
+func knownDeps(u runner.User, known map[runner.UserID]bool) ([]runner.UserID, []runner.DepGroup) {
+	var deps []runner.UserID
+	for _, dep := range u.Deps {
+		if known[dep] {
+			deps = append(deps, dep)
+		}
+	}
+	var groups []runner.DepGroup
+	for _, g := range u.DepGroups {
+		var users []runner.UserID
+		for _, dep := range g.Users {
+			if known[dep] {
+				users = append(users, dep)
+			}
+		}
+		if len(users) == 0 {
+			continue
+		}
+		groups = append(groups, runner.DepGroup{Users: users, Min: min(g.Min, len(users))})
+	}
+	return deps, groups
+}
+
+// Availabilities returns the passes for each partition.
+// Partitions constrained by a ratio get the best split of the ratio's total.
 func (r *RunConfig) Availabilities() []runner.Availability {
 	var availabilities []runner.Availability
 	for part, passes := range r.Passes {
@@ -84,5 +285,13 @@ func (r *RunConfig) Availabilities() []runner.Availability {
 			Available: passes,
 		})
 	}
-	return availabilities
+	if len(r.Ratios) == 0 {
+		return availabilities
+	}
+
+	demand := make(map[runner.Partition]int)
+	for part, partUsers := range r.Users {
+		demand[part] = len(partUsers)
+	}
+	return runner.Balance(availabilities, r.Ratios, demand)
 }
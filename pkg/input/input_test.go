@@ -0,0 +1,234 @@
+package input_test
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/wchresta/passdraw/pkg/input"
+	"github.com/wchresta/passdraw/pkg/runner"
+)
+
+func TestNewFromJSON_Groups(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		groups  string
+		wantErr string
+	}{
+		{
+			name:   "valid groups",
+			groups: `[{"ID": "crew", "Members": ["L1", "F1"]}, {"ID": "pair", "Members": ["L2", "F2"]}]`,
+		},
+		{
+			name:    "unknown member",
+			groups:  `[{"ID": "crew", "Members": ["L1", "X"]}]`,
+			wantErr: "member X of group crew is not a registered user",
+		},
+		{
+			name:    "member of two groups",
+			groups:  `[{"ID": "crew", "Members": ["L1", "F1"]}, {"ID": "pair", "Members": ["L1", "F2"]}]`,
+			wantErr: "user L1 is member of groups crew and pair",
+		},
+		{
+			name:    "duplicate group",
+			groups:  `[{"ID": "crew", "Members": ["L1"]}, {"ID": "crew", "Members": ["F1"]}]`,
+			wantErr: "group crew is listed multiple times",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := input.NewFromJSON([]byte(`{
+				"Passes": {"Leader": 1, "Follow": 1},
+				"Users": {
+					"Leader": [{"ID": "L1"}, {"ID": "L2"}],
+					"Follow": [{"ID": "F1"}, {"ID": "F2"}]
+				},
+				"Groups": ` + tc.groups + `
+			}`))
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Errorf("NewFromJSON failed unexpectedly: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("NewFromJSON returned unexpected error: got %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		users string
+		want  input.ValidationErrors
+	}{
+		{
+			name:  "valid",
+			users: `"Leader": [{"ID": "L1", "Deps": ["F1"]}], "Follow": [{"ID": "F1"}]`,
+		},
+		{
+			name:  "duplicate user",
+			users: `"Leader": [{"ID": "L1"}], "Follow": [{"ID": "F1"}, {"ID": "L1"}]`,
+			want: input.ValidationErrors{
+				{Path: "$.Users.Leader[0].ID", Message: "user L1 is registered multiple times, first at $.Users.Follow[1]"},
+			},
+		},
+		{
+			name:  "unknown and self dependencies",
+			users: `"Leader": [{"ID": "L1", "Deps": ["L1", "X"], "DepGroups": [{"Users": ["F1", "Y"]}]}], "Follow": [{"ID": "F1"}]`,
+			want: input.ValidationErrors{
+				{Path: "$.Users.Leader[0].Deps[0]", Message: "user L1 depends on themselves"},
+				{Path: "$.Users.Leader[0].Deps[1]", Message: "user L1 depends on X, which is not a registered user"},
+				{Path: "$.Users.Leader[0].DepGroups[0].Users[1]", Message: "user L1 depends on Y, which is not a registered user"},
+			},
+		},
+		{
+			name:  "unknown state",
+			users: `"Leader": [{"ID": "L1"}], "Follow": [{"ID": "F1", "State": "vip"}]`,
+			want: input.ValidationErrors{
+				{Path: "$.Users.Follow[0].State", Message: `user F1 has unknown state "vip"`},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := input.NewFromJSON([]byte(`{
+				"Passes": {"Leader": 1, "Follow": 1},
+				"Users": {` + tc.users + `}
+			}`))
+			if tc.want == nil {
+				if err != nil {
+					t.Errorf("NewFromJSON failed unexpectedly: %s", err)
+				}
+				return
+			}
+			var got input.ValidationErrors
+			if !errors.As(err, &got) {
+				t.Fatalf("NewFromJSON returned unexpected error: got %v, want validation errors", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("NewFromJSON returned unexpected errors:\ngot  %v\nwant %v", got, tc.want)
+			}
+		})
+	}
+
+	t.Run("NaN weight", func(t *testing.T) {
+		conf := input.RunConfig{
+			Passes: map[runner.Partition]int{"Leader full": 1},
+			Users:  map[runner.Partition][]input.User{"Leader full": {{ID: "L1", Weight: math.NaN()}}},
+		}
+		want := input.ValidationErrors{
+			{Path: `$.Users["Leader full"][0].Weight`, Message: "user L1 has invalid weight NaN"},
+		}
+		if got := conf.Validate(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Validate returned unexpected errors:\ngot  %v\nwant %v", got, want)
+		}
+	})
+
+	t.Run("group with some guaranteed members", func(t *testing.T) {
+		conf := input.RunConfig{
+			Passes: map[runner.Partition]int{"Leader": 2, "Follow": 2},
+			Users: map[runner.Partition][]input.User{
+				"Leader": {{ID: "L1", State: runner.Guaranteed}, {ID: "L2", State: runner.Guaranteed}},
+				"Follow": {{ID: "F1"}, {ID: "F2", State: runner.Guaranteed}},
+			},
+			Groups: []input.Group{
+				{ID: "mixed", Members: []runner.UserID{"L1", "F1"}},
+				{ID: "teachers", Members: []runner.UserID{"L2", "F2"}},
+			},
+		}
+		want := input.ValidationErrors{
+			{Path: "$.Groups[0].Members", Message: "group mixed has 1 guaranteed members, but either all or no members must be guaranteed"},
+		}
+		if got := conf.Validate(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Validate returned unexpected errors:\ngot  %v\nwant %v", got, want)
+		}
+	})
+
+	t.Run("guaranteed users exceed ratio split", func(t *testing.T) {
+		conf := input.RunConfig{
+			Passes: map[runner.Partition]int{"Leader": 5, "Follow": 5},
+			Users: map[runner.Partition][]input.User{
+				"Leader": {{ID: "L1", State: runner.Guaranteed}, {ID: "L2", State: runner.Guaranteed}, {ID: "L3", State: runner.Guaranteed}},
+				"Follow": {{ID: "F1"}, {ID: "F2"}},
+			},
+			Ratios: []runner.Ratio{{Left: "Leader", Right: "Follow", Total: 4, Min: 1, Max: 1}},
+		}
+		want := input.ValidationErrors{
+			{Path: "$.Ratios[0]", Message: "ratio Leader:Follow leaves partition Leader 2 passes, but it has 3 guaranteed users"},
+		}
+		if got := conf.Validate(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Validate returned unexpected errors:\ngot  %v\nwant %v", got, want)
+		}
+	})
+}
+
+func TestDanglingPolicy(t *testing.T) {
+	config := func(policy string) string {
+		return `{
+			"Passes": {"Leader": 2, "Follow": 2},
+			"Users": {
+				"Leader": [
+					{"ID": "L1", "Deps": ["Ghost"]},
+					{"ID": "L2", "DepGroups": [{"Users": ["F1", "Ghost"]}]},
+					{"ID": "L3", "DepGroups": [{"Users": ["F1", "Ghost"], "Min": 2}]}
+				],
+				"Follow": [{"ID": "F1"}, {"ID": "F2", "Deps": ["Ghost"], "State": "guaranteed"}]
+			},
+			"DanglingDeps": "` + policy + `"
+		}`
+	}
+
+	if _, err := input.NewFromJSON([]byte(config(""))); err == nil || !strings.Contains(err.Error(), "user L1 depends on Ghost") {
+		t.Errorf("NewFromJSON returned unexpected error for default policy: %v", err)
+	}
+	if _, err := input.NewFromJSON([]byte(config("sometimes"))); err == nil || !strings.Contains(err.Error(), "unknown dangling dependency policy") {
+		t.Errorf("NewFromJSON returned unexpected error for unknown policy: %v", err)
+	}
+
+	for _, tc := range []struct {
+		policy    string
+		wantState map[runner.UserID]runner.State
+		wantDeps  map[runner.UserID]int
+	}{
+		{
+			policy:    "refuse",
+			wantState: map[runner.UserID]runner.State{"L1": runner.Excluded, "L2": runner.Drawn, "L3": runner.Excluded, "F2": runner.Guaranteed},
+			wantDeps:  map[runner.UserID]int{"L1": 1, "L2": 2, "L3": 2},
+		},
+		{
+			policy:    "ignore",
+			wantState: map[runner.UserID]runner.State{"L1": runner.Drawn, "L2": runner.Drawn, "L3": runner.Drawn, "F2": runner.Guaranteed},
+			wantDeps:  map[runner.UserID]int{"L1": 0, "L2": 1, "L3": 1},
+		},
+	} {
+		t.Run(tc.policy, func(t *testing.T) {
+			conf, err := input.NewFromJSON([]byte(config(tc.policy)))
+			if err != nil {
+				t.Fatalf("NewFromJSON failed unexpectedly: %s", err)
+			}
+			if got := conf.Dangling(); len(got) != 4 {
+				t.Errorf("Dangling returned unexpected users: %v", got)
+			}
+
+			p := conf.Problem()
+			for id, want := range tc.wantState {
+				if u, _ := p.User(id); u.State != want {
+					t.Errorf("User %s has unexpected state: got %q, want %q", id, u.State, want)
+				}
+			}
+			for id, want := range tc.wantDeps {
+				u, _ := p.User(id)
+				got := len(u.Deps)
+				for _, g := range u.DepGroups {
+					got += len(g.Users)
+				}
+				if got != want {
+					t.Errorf("User %s has unexpected amount of dependencies: got %d, want %d", id, got, want)
+				}
+			}
+		})
+	}
+}
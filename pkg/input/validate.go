@@ -0,0 +1,289 @@
+package input
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/wchresta/passdraw/pkg/runner"
+)
+
+// ValidationError is a problem with a value of a config.
+type ValidationError struct {
+	// Path locates the value in the json input, e.g. `$.Users.leader_full[3].Deps[0]`.
+	Path    string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// ValidationErrors lists all problems found in a config. Partitions are checked in sorted
+// order, and the users of a partition in the order of the input; a duplicate user is
+// reported at every occurrence after the first in that order.
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	lines := make([]string, 0, len(errs))
+	for _, e := range errs {
+		lines = append(lines, "value error: "+e.Error())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Validate checks the config and returns all problems found, or nil if it is valid.
+func (r *RunConfig) Validate() ValidationErrors {
+	v := validator{
+		config: r,
+		userAt: make(map[runner.UserID]string),
+	}
+	switch r.DanglingPolicy() {
+	case DanglingFail, DanglingRefuse, DanglingIgnore:
+	default:
+		v.errorf("$.DanglingDeps", "unknown dangling dependency policy %q, must be one of fail, refuse, ignore", r.DanglingDeps)
+	}
+	v.validatePartitions()
+	v.validateUsers()
+	v.validateGroups()
+	v.validateRatios()
+	return v.errs
+}
+
+// parseErrors turns an error of json.Unmarshal into validation errors.
+func parseErrors(err error) ValidationErrors {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return ValidationErrors{{Path: "$." + typeErr.Field, Message: "format error: " + err.Error()}}
+	}
+	return ValidationErrors{{Path: "$", Message: "format error: " + err.Error()}}
+}
+
+type validator struct {
+	config *RunConfig
+	// userAt holds the path of every registered user.
+	userAt map[runner.UserID]string
+	errs   ValidationErrors
+}
+
+func (v *validator) errorf(path string, format string, args ...any) {
+	v.errs = append(v.errs, ValidationError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// member returns the path to the member of an object.
+func member(path string, key string) string {
+	if identifier.MatchString(key) {
+		return path + "." + key
+	}
+	return path + "[" + strconv.Quote(key) + "]"
+}
+
+// index returns the path to the element of an array.
+func index(path string, i int) string {
+	return fmt.Sprintf("%s[%d]", path, i)
+}
+
+func (v *validator) partitions() []runner.Partition {
+	return slices.Sorted(maps.Keys(v.config.Users))
+}
+
+func (v *validator) validatePartitions() {
+	for _, part := range slices.Sorted(maps.Keys(v.config.Passes)) {
+		path := member("$.Passes", string(part))
+		if _, ok := v.config.Users[part]; !ok {
+			v.errorf(path, "found partition %s with passes but no users", part)
+		}
+		if n := v.config.Passes[part]; n < 0 {
+			v.errorf(path, "partition %s has negative passes %d", part, n)
+		}
+	}
+	for _, part := range v.partitions() {
+		if _, ok := v.config.Passes[part]; !ok {
+			v.errorf(member("$.Users", string(part)), "found partition %s with users but no passes", part)
+		}
+	}
+}
+
+func (v *validator) validateUsers() {
+	// Register all users first, so dependencies can point forward.
+	for _, part := range v.partitions() {
+		guaranteed := 0
+		for i, u := range v.config.Users[part] {
+			path := index(member("$.Users", string(part)), i)
+			if u.State == runner.Guaranteed {
+				guaranteed++
+			}
+			if u.ID == "" {
+				v.errorf(member(path, "ID"), "user id cannot be empty")
+				continue
+			}
+			if other, ok := v.userAt[u.ID]; ok {
+				v.errorf(member(path, "ID"), "user %s is registered multiple times, first at %s", u.ID, other)
+				continue
+			}
+			v.userAt[u.ID] = path
+		}
+		if passes, ok := v.config.Passes[part]; ok && guaranteed > passes {
+			v.errorf(member("$.Users", string(part)), "partition %s has %d guaranteed users, but only %d passes", part, guaranteed, passes)
+		}
+	}
+
+	for _, part := range v.partitions() {
+		for i, u := range v.config.Users[part] {
+			v.validateUser(part, index(member("$.Users", string(part)), i), u)
+		}
+	}
+}
+
+func (v *validator) validateUser(part runner.Partition, path string, u User) {
+	switch u.State {
+	case runner.Drawn, runner.Guaranteed, runner.Excluded:
+	default:
+		v.errorf(member(path, "State"), "user %s has unknown state %q", u.ID, u.State)
+	}
+
+	if math.IsNaN(u.Weight) || math.IsInf(u.Weight, 0) {
+		v.errorf(member(path, "Weight"), "user %s has invalid weight %f", u.ID, u.Weight)
+	}
+
+	seenFallbacks := map[runner.Partition]bool{part: true}
+	for i, fb := range u.Fallbacks {
+		fbPath := index(member(path, "Fallbacks"), i)
+		if _, ok := v.config.Passes[fb]; !ok {
+			v.errorf(fbPath, "user %s has unknown fallback partition %s", u.ID, fb)
+		}
+		if seenFallbacks[fb] {
+			v.errorf(fbPath, "user %s lists partition %s multiple times", u.ID, fb)
+		}
+		seenFallbacks[fb] = true
+	}
+
+	for i, dep := range u.Deps {
+		v.validateDep(index(member(path, "Deps"), i), u, dep)
+	}
+	for i, g := range u.DepGroups {
+		gPath := index(member(path, "DepGroups"), i)
+		if len(g.Users) == 0 {
+			v.errorf(gPath, "user %s has an empty dependency group", u.ID)
+		}
+		if g.Min < 0 || g.Min > len(g.Users) {
+			v.errorf(member(gPath, "Min"), "user %s has a dependency group with Min %d, but %d users", u.ID, g.Min, len(g.Users))
+		}
+		for j, dep := range g.Users {
+			v.validateDep(index(member(gPath, "Users"), j), u, dep)
+		}
+	}
+}
+
+func (v *validator) validateDep(path string, u User, dep runner.UserID) {
+	if dep == u.ID {
+		v.errorf(path, "user %s depends on themselves", u.ID)
+		return
+	}
+	if _, ok := v.userAt[dep]; !ok && v.config.DanglingPolicy() == DanglingFail {
+		v.errorf(path, "user %s depends on %s, which is not a registered user", u.ID, dep)
+	}
+}
+
+func (v *validator) validateGroups() {
+	guaranteed := make(map[runner.UserID]bool)
+	for _, part := range v.partitions() {
+		for _, u := range v.config.Users[part] {
+			if u.State == runner.Guaranteed {
+				guaranteed[u.ID] = true
+			}
+		}
+	}
+
+	seenGroups := make(map[runner.GroupID]bool)
+	groupOf := make(map[runner.UserID]runner.GroupID)
+	for i, g := range v.config.Groups {
+		path := index("$.Groups", i)
+		if g.ID == "" {
+			v.errorf(member(path, "ID"), "group id cannot be empty")
+		} else if seenGroups[g.ID] {
+			v.errorf(member(path, "ID"), "group %s is listed multiple times", g.ID)
+		}
+		seenGroups[g.ID] = true
+
+		for j, m := range g.Members {
+			mPath := index(member(path, "Members"), j)
+			if _, ok := v.userAt[m]; !ok {
+				v.errorf(mPath, "member %s of group %s is not a registered user", m, g.ID)
+				continue
+			}
+			if other, ok := groupOf[m]; ok {
+				v.errorf(mPath, "user %s is member of groups %s and %s", m, other, g.ID)
+				continue
+			}
+			groupOf[m] = g.ID
+		}
+
+		// Guaranteed users are never refused, so their group could not be refused as a whole.
+		numGuaranteed := 0
+		for _, m := range g.Members {
+			if guaranteed[m] {
+				numGuaranteed++
+			}
+		}
+		if numGuaranteed > 0 && numGuaranteed < len(g.Members) {
+			v.errorf(member(path, "Members"), "group %s has %d guaranteed members, but either all or no members must be guaranteed", g.ID, numGuaranteed)
+		}
+	}
+}
+
+func (v *validator) validateRatios() {
+	numErrs := len(v.errs)
+	inRatio := make(map[runner.Partition]bool)
+	for i, ratio := range v.config.Ratios {
+		path := index("$.Ratios", i)
+		for _, side := range []struct {
+			field string
+			part  runner.Partition
+		}{{"Left", ratio.Left}, {"Right", ratio.Right}} {
+			if _, ok := v.config.Passes[side.part]; !ok {
+				v.errorf(member(path, side.field), "ratio uses unknown partition %s", side.part)
+			}
+			if inRatio[side.part] {
+				v.errorf(member(path, side.field), "partition %s is used by multiple ratios", side.part)
+			}
+			inRatio[side.part] = true
+		}
+		if ratio.Total < 0 {
+			v.errorf(member(path, "Total"), "ratio %s:%s has negative total %d", ratio.Left, ratio.Right, ratio.Total)
+		}
+		if !(ratio.Min > 0 && ratio.Min <= ratio.Max) {
+			v.errorf(path, "ratio %s:%s must have 0 < Min <= Max, got Min %f and Max %f", ratio.Left, ratio.Right, ratio.Min, ratio.Max)
+		}
+	}
+	if len(v.errs) > numErrs || len(v.config.Ratios) == 0 {
+		return
+	}
+
+	// Guaranteed users must fit into the passes the ratios leave their partitions.
+	available := make(map[runner.Partition]int)
+	for _, a := range v.config.Availabilities() {
+		available[a.Partition] = a.Available
+	}
+	for i, ratio := range v.config.Ratios {
+		for _, part := range []runner.Partition{ratio.Left, ratio.Right} {
+			guaranteed := 0
+			for _, u := range v.config.Users[part] {
+				if u.State == runner.Guaranteed {
+					guaranteed++
+				}
+			}
+			if guaranteed > available[part] {
+				v.errorf(index("$.Ratios", i), "ratio %s:%s leaves partition %s %d passes, but it has %d guaranteed users", ratio.Left, ratio.Right, part, available[part], guaranteed)
+			}
+		}
+	}
+}
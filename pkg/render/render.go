@@ -0,0 +1,274 @@
+// Package render draws the dependencies between users, e.g. to show why users were refused together.
+//
+// Users are drawn as nodes, grouped by the partition they registered for.
+// Deps are drawn as solid arrows from a user to the users they depend on,
+// DepGroups as dashed arrows labeled with how many of the group are needed,
+// and members of a group are connected by dotted lines.
+package render
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/wchresta/passdraw/pkg/runner"
+)
+
+// Format is an output format of Graph.
+type Format string
+
+const (
+	// Dot is the language of Graphviz, e.g. `dot -Tsvg graph.dot > graph.svg`.
+	Dot Format = "dot"
+	// Mermaid is a flowchart that renders in markdown on many platforms.
+	Mermaid Format = "mermaid"
+)
+
+// Formats lists all formats.
+var Formats = []Format{Dot, Mermaid}
+
+// Outcome is what happened to a user in a solution.
+type Outcome string
+
+const (
+	Pass       Outcome = "pass"
+	Waitlisted Outcome = "waitlisted"
+	// Refused users did not get a pass and are not on the waitlist, e.g. excluded users.
+	Refused Outcome = "refused"
+)
+
+// color is the fill color of the nodes of every outcome.
+var color = map[Outcome]string{
+	Pass:       "#8fd694",
+	Waitlisted: "#f6c85f",
+	Refused:    "#ef767a",
+}
+
+// Outcomes returns the outcome of every user of the problem in the solution.
+func Outcomes(p *runner.Problem, solution *runner.Solution) map[runner.UserID]Outcome {
+	outcomes := make(map[runner.UserID]Outcome)
+	for _, u := range p.AllUsers() {
+		outcomes[u.ID] = Refused
+	}
+	for _, users := range solution.Waitlist {
+		for _, id := range users {
+			outcomes[id] = Waitlisted
+		}
+	}
+	for _, users := range solution.Passes {
+		for _, id := range users {
+			outcomes[id] = Pass
+		}
+	}
+	return outcomes
+}
+
+type Options struct {
+	// All includes users without any dependencies; by default, only users that
+	// depend on others, are depended on, or are in a group are drawn.
+	All bool
+	// Outcomes, if not nil, colors every user by their outcome.
+	Outcomes map[runner.UserID]Outcome
+}
+
+// Graph writes the dependency graph of the problem in the given format.
+func Graph(w io.Writer, format Format, p *runner.Problem, opts Options) error {
+	g := newGraph(p, opts)
+	var r renderer
+	switch format {
+	case Dot:
+		r = &dot{}
+	case Mermaid:
+		r = &mermaid{}
+	default:
+		return fmt.Errorf("unknown format %q, must be one of %v", format, Formats)
+	}
+	r.render(g, opts.Outcomes)
+	_, err := io.WriteString(w, r.String())
+	return err
+}
+
+// graph holds the nodes and edges to render, in a stable order.
+type graph struct {
+	partitions []runner.Partition
+	// nodes holds the users to draw of every partition.
+	nodes map[runner.Partition][]runner.UserID
+	// name holds a short node name for every user, as user IDs can contain any character.
+	name  map[runner.UserID]string
+	edges []edge
+}
+
+type edgeKind int
+
+const (
+	depEdge edgeKind = iota
+	depGroupEdge
+	groupEdge
+)
+
+type edge struct {
+	kind     edgeKind
+	from, to runner.UserID
+	label    string
+}
+
+func newGraph(p *runner.Problem, opts Options) *graph {
+	users := p.AllUsers()
+	known := make(map[runner.UserID]bool, len(users))
+	for _, u := range users {
+		known[u.ID] = true
+	}
+
+	g := &graph{
+		nodes: make(map[runner.Partition][]runner.UserID),
+		name:  make(map[runner.UserID]string),
+	}
+	connected := make(map[runner.UserID]bool)
+	connect := func(e edge) {
+		// Dependencies on unknown users were dropped or refused before the draw.
+		if !known[e.to] {
+			return
+		}
+		g.edges = append(g.edges, e)
+		connected[e.from] = true
+		connected[e.to] = true
+	}
+
+	groups := make(map[runner.GroupID][]runner.UserID)
+	var groupIDs []runner.GroupID
+	for _, u := range users {
+		for _, dep := range u.Deps {
+			connect(edge{kind: depEdge, from: u.ID, to: dep})
+		}
+		for _, dg := range u.DepGroups {
+			label := fmt.Sprintf("%d of %d", dg.Required(), len(dg.Users))
+			for _, dep := range dg.Users {
+				connect(edge{kind: depGroupEdge, from: u.ID, to: dep, label: label})
+			}
+		}
+		if u.Group != "" {
+			if _, ok := groups[u.Group]; !ok {
+				groupIDs = append(groupIDs, u.Group)
+			}
+			groups[u.Group] = append(groups[u.Group], u.ID)
+		}
+	}
+	for _, id := range groupIDs {
+		members := groups[id]
+		for i := 1; i < len(members); i++ {
+			connect(edge{kind: groupEdge, from: members[i-1], to: members[i], label: string(id)})
+		}
+	}
+
+	for _, u := range users {
+		if !opts.All && !connected[u.ID] {
+			continue
+		}
+		if _, ok := g.nodes[u.Partition]; !ok {
+			g.partitions = append(g.partitions, u.Partition)
+		}
+		g.nodes[u.Partition] = append(g.nodes[u.Partition], u.ID)
+		g.name[u.ID] = "u" + strconv.Itoa(len(g.name))
+	}
+	slices.Sort(g.partitions)
+	return g
+}
+
+type renderer interface {
+	render(g *graph, outcomes map[runner.UserID]Outcome)
+	String() string
+}
+
+type dot struct {
+	strings.Builder
+}
+
+// quoteDot quotes s as a DOT string.
+func quoteDot(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func (d *dot) render(g *graph, outcomes map[runner.UserID]Outcome) {
+	d.WriteString("digraph passdraw {\n")
+	d.WriteString("  rankdir=LR;\n")
+	d.WriteString("  node [shape=box, style=\"rounded,filled\", fillcolor=white];\n")
+	for i, part := range g.partitions {
+		fmt.Fprintf(d, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(d, "    label=%s;\n", quoteDot(string(part)))
+		for _, id := range g.nodes[part] {
+			fmt.Fprintf(d, "    %s [label=%s", g.name[id], quoteDot(string(id)))
+			if outcome, ok := outcomes[id]; ok {
+				fmt.Fprintf(d, ", fillcolor=%s, tooltip=%s", quoteDot(color[outcome]), quoteDot(string(outcome)))
+			}
+			d.WriteString("];\n")
+		}
+		d.WriteString("  }\n")
+	}
+	for _, e := range g.edges {
+		fmt.Fprintf(d, "  %s -> %s", g.name[e.from], g.name[e.to])
+		switch e.kind {
+		case depGroupEdge:
+			fmt.Fprintf(d, " [style=dashed, label=%s]", quoteDot(e.label))
+		case groupEdge:
+			fmt.Fprintf(d, " [style=dotted, dir=none, label=%s]", quoteDot(e.label))
+		}
+		d.WriteString(";\n")
+	}
+	if outcomes != nil {
+		d.WriteString("  subgraph cluster_legend {\n")
+		d.WriteString("    label=\"Outcome\";\n")
+		for _, outcome := range []Outcome{Pass, Waitlisted, Refused} {
+			fmt.Fprintf(d, "    legend_%s [label=%s, fillcolor=%s];\n", outcome, quoteDot(string(outcome)), quoteDot(color[outcome]))
+		}
+		d.WriteString("  }\n")
+	}
+	d.WriteString("}\n")
+}
+
+type mermaid struct {
+	strings.Builder
+}
+
+// quoteMermaid quotes s as a Mermaid label.
+func quoteMermaid(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, "#quot;") + `"`
+}
+
+func (m *mermaid) render(g *graph, outcomes map[runner.UserID]Outcome) {
+	m.WriteString("flowchart LR\n")
+	for i, part := range g.partitions {
+		fmt.Fprintf(m, "  subgraph p%d[%s]\n", i, quoteMermaid(string(part)))
+		for _, id := range g.nodes[part] {
+			fmt.Fprintf(m, "    %s(%s)\n", g.name[id], quoteMermaid(string(id)))
+		}
+		m.WriteString("  end\n")
+	}
+	for _, e := range g.edges {
+		switch e.kind {
+		case depEdge:
+			fmt.Fprintf(m, "  %s --> %s\n", g.name[e.from], g.name[e.to])
+		case depGroupEdge:
+			fmt.Fprintf(m, "  %s -.->|%s| %s\n", g.name[e.from], quoteMermaid(e.label), g.name[e.to])
+		case groupEdge:
+			fmt.Fprintf(m, "  %s -.-|%s| %s\n", g.name[e.from], quoteMermaid(e.label), g.name[e.to])
+		}
+	}
+	if outcomes != nil {
+		for _, outcome := range []Outcome{Pass, Waitlisted, Refused} {
+			var names []string
+			for _, part := range g.partitions {
+				for _, id := range g.nodes[part] {
+					if outcomes[id] == outcome {
+						names = append(names, g.name[id])
+					}
+				}
+			}
+			fmt.Fprintf(m, "  classDef %s fill:%s\n", outcome, color[outcome])
+			if len(names) > 0 {
+				fmt.Fprintf(m, "  class %s %s\n", strings.Join(names, ","), outcome)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package render_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/wchresta/passdraw/pkg/input"
+	"github.com/wchresta/passdraw/pkg/render"
+)
+
+func TestGraph(t *testing.T) {
+	conf, err := input.NewFromJSON([]byte(`{
+		"Passes": {"Leader": 1, "Follow": 1},
+		"Users": {
+			"Leader": [{"ID": "L1", "Deps": ["F1"]}, {"ID": "L\"2", "DepGroups": [{"Users": ["F1", "F2"]}]}, {"ID": "L3"}],
+			"Follow": [{"ID": "F1", "Deps": ["L1"]}, {"ID": "F2"}, {"ID": "F3"}]
+		},
+		"Groups": [{"ID": "friends", "Members": ["L3", "F2"]}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	problem := conf.Problem()
+	solution, err := problem.Run(rand.New(rand.NewSource(1)), conf.Availabilities())
+	if err != nil {
+		t.Fatal(err)
+	}
+	outcomes := render.Outcomes(problem, solution)
+	for _, u := range problem.AllUsers() {
+		if outcomes[u.ID] == "" {
+			t.Errorf("user %s has no outcome", u.ID)
+		}
+	}
+
+	var out strings.Builder
+	if err := render.Graph(&out, render.Dot, problem, render.Options{Outcomes: outcomes}); err != nil {
+		t.Fatal(err)
+	}
+	dot := out.String()
+	for _, want := range []string{
+		`label="Leader"`,
+		`label="L\"2"`,
+		`[style=dashed, label="1 of 2"]`,
+		`[style=dotted, dir=none, label="friends"]`,
+		`tooltip="` + string(outcomes["L1"]) + `"`,
+	} {
+		if !strings.Contains(dot, want) {
+			t.Errorf("dot output does not contain %s:\n%s", want, dot)
+		}
+	}
+	if strings.Contains(dot, `label="F3"`) {
+		t.Errorf("dot output contains F3 without dependencies:\n%s", dot)
+	}
+
+	out.Reset()
+	if err := render.Graph(&out, render.Mermaid, problem, render.Options{All: true}); err != nil {
+		t.Fatal(err)
+	}
+	mermaid := out.String()
+	for _, want := range []string{
+		"flowchart LR\n",
+		`("L#quot;2")`,
+		`("F3")`,
+		`-.->|"1 of 2"|`,
+		`-.-|"friends"|`,
+	} {
+		if !strings.Contains(mermaid, want) {
+			t.Errorf("mermaid output does not contain %s:\n%s", want, mermaid)
+		}
+	}
+	if strings.Contains(mermaid, "classDef") {
+		t.Errorf("mermaid output has classes without outcomes:\n%s", mermaid)
+	}
+
+	if err := render.Graph(&out, "svg", problem, render.Options{}); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
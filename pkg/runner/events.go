@@ -0,0 +1,156 @@
+package runner
+
+import (
+	"fmt"
+	"iter"
+	"math/rand"
+)
+
+// EventKind tells what happened in a step of the draw.
+type EventKind string
+
+const (
+	// EventStageStarted starts a stage of the draw; see Problem.Run.
+	EventStageStarted EventKind = "stage-started"
+	// EventRoundStarted starts a round of the stage, in which the strategy
+	// refuses at most one user of every partition.
+	EventRoundStarted EventKind = "round-started"
+	// EventUserExcluded refuses an excluded user before the draw.
+	EventUserExcluded EventKind = "user-excluded"
+	// EventUserDrawn refuses a user drawn by the strategy.
+	EventUserDrawn EventKind = "user-drawn"
+	// EventCascadeRefusal refuses a user because a user they depend on,
+	// or a member of their group, was refused.
+	EventCascadeRefusal EventKind = "cascade-refusal"
+	// EventPartitionClosed tells that no more users are drawn for the partition in this stage.
+	// Users can still be refused for it by cascades.
+	EventPartitionClosed EventKind = "partition-closed"
+	// EventFinished ends the draw with its solution.
+	EventFinished EventKind = "finished"
+)
+
+// Event is a step of the draw.
+type Event struct {
+	Kind  EventKind
+	Stage int
+	Round int
+	// Partition is the partition a user was refused for, or that was closed.
+	Partition Partition
+	// User is the refused user of refusal events.
+	User UserID
+	// Chain lists the users a cascade refusal cascaded through; see Refusal.
+	Chain []UserID
+	// Solution is the solution of EventFinished; the same Run would return.
+	Solution *Solution
+}
+
+// Events is the same as Problem.Events, with the strategy and rand of the runner.
+func (r *Runner) Events(availabilities []Availability) (iter.Seq[Event], error) {
+	return r.problem.Events(r.strategy, r.rand, availabilities)
+}
+
+// Events draws the passes like RunStrategy, and yields every step of the draw as it happens.
+// The last event is EventFinished holding the solution, which is the same RunStrategy
+// returns for a rand in the same state.
+//
+// Every iteration draws anew, advancing the rand. Stopping the iteration early
+// still advances the rand as if the draw was finished.
+// Returns an error if the guaranteed users do not fit into the available passes.
+func (p *Problem) Events(strategy Strategy, rand *rand.Rand, availabilities []Availability) (iter.Seq[Event], error) {
+	if rand == nil {
+		panic("rand cannot be nil")
+	}
+	if err := p.checkGuaranteed(p.available(availabilities)); err != nil {
+		return nil, err
+	}
+	return func(yield func(Event) bool) {
+		s := newState(p, rand)
+		s.startTrace()
+		stopped := false
+		s.emit = func(e Event) {
+			if !stopped && !yield(e) {
+				stopped = true
+			}
+		}
+		if err := s.run(strategy, availabilities); err != nil {
+			// Cannot happen, the guaranteed users were checked.
+			panic(err)
+		}
+		if !stopped {
+			yield(Event{Kind: EventFinished, Stage: s.stage, Round: s.round, Solution: s.solution()})
+		}
+	}, nil
+}
+
+// checkGuaranteed returns an error if the guaranteed users do not fit into the available passes.
+func (p *Problem) checkGuaranteed(available []int) error {
+	guaranteed := make([]int, len(p.partitions))
+	for u, user := range p.users {
+		if user.State == Guaranteed {
+			guaranteed[p.prefs[u][0]]++
+		}
+	}
+	for part, n := range guaranteed {
+		if n > available[part] {
+			return fmt.Errorf("partition %s has %d guaranteed users, but only %d passes", p.partitions[part], n, available[part])
+		}
+	}
+	return nil
+}
+
+// notify emits the event, if events are needed, after announcing the current round.
+func (s *state) notify(e Event) {
+	if s.emit == nil {
+		return
+	}
+	e.Stage = s.stage
+	e.Round = s.round
+	if s.roundPending && e.Kind != EventStageStarted {
+		s.roundPending = false
+		s.emit(Event{Kind: EventRoundStarted, Stage: s.stage, Round: s.round})
+	}
+	s.emit(e)
+}
+
+// nextRound starts the next round of the stage.
+// The round is only announced once something happens in it.
+func (s *state) nextRound() {
+	s.round++
+	s.roundPending = true
+}
+
+// openPartitions opens all partitions with candidates at the start of a stage.
+func (s *state) openPartitions() {
+	for part := range s.closed {
+		s.closed[part] = s.numCandidates[part] == 0
+	}
+}
+
+// closePartition tells that no more users are drawn for the partition in this stage.
+func (s *state) closePartition(part int) {
+	if s.closed[part] {
+		return
+	}
+	s.closed[part] = true
+	s.notify(Event{Kind: EventPartitionClosed, Partition: s.p.partitions[part]})
+}
+
+// notifyRefusal emits the refusal of user u, right after it was traced.
+func (s *state) notifyRefusal(u int, reason Reason) {
+	if s.emit == nil {
+		return
+	}
+	kind := EventCascadeRefusal
+	switch reason {
+	case ReasonDrawn:
+		kind = EventUserDrawn
+	case ReasonExcluded:
+		kind = EventUserExcluded
+	}
+	s.notify(Event{
+		Kind:      kind,
+		Partition: s.p.partitions[s.assigned[u]],
+		User:      s.p.users[u].ID,
+		Chain:     s.chain(s.lastRefusal[u]),
+	})
+}
@@ -0,0 +1,214 @@
+package runner
+
+import (
+	"encoding/binary"
+	"fmt"
+	"slices"
+)
+
+// MaxExactStates limits the amount of distinct draw states Probabilities explores.
+const MaxExactStates = 1 << 21
+
+// Probabilities computes the exact probability of every user to get a pass with Run.
+//
+// It enumerates every sequence of refusals Run can draw, weighted by its probability.
+// Sequences that lead to the same state of the draw are only explored once,
+// and users without constraints that only differ in their ID are treated as one.
+// The amount of states still grows exponentially with the amount of users,
+// so this is only feasible for small problems of up to a few dozen users.
+// Returns an error if more than MaxExactStates states would be needed.
+func (p *Problem) Probabilities(availabilities []Availability) (map[UserID]float64, error) {
+	available := p.available(availabilities)
+	s := newState(p, nil)
+	if err := s.start(available); err != nil {
+		return nil, err
+	}
+
+	s.refusals = nil
+
+	e := &exact{
+		p:         p,
+		available: available,
+		class:     p.interchangeable(),
+		memo:      make(map[string][]float64),
+	}
+	probs, err := e.stage(s)
+	if err != nil {
+		return nil, err
+	}
+
+	// We always refuse the first candidate of a class; spread its probability
+	// over the whole class.
+	classProbs := make(map[int]float64)
+	classSize := make(map[int]int)
+	for u, c := range e.class {
+		if c >= 0 {
+			classProbs[c] += probs[u]
+			classSize[c]++
+		}
+	}
+	result := make(map[UserID]float64, len(p.users))
+	for u, prob := range probs {
+		if c := e.class[u]; c >= 0 {
+			prob = classProbs[c] / float64(classSize[c])
+		}
+		result[p.users[u].ID] = prob
+	}
+	return result, nil
+}
+
+// interchangeable returns for every user the first user that is interchangeable with it,
+// or -1 if it has constraints.
+// Interchangeable users have no constraints, and the same preferences and weights.
+func (p *Problem) interchangeable() []int {
+	type class struct {
+		prefs  string
+		weight float64
+	}
+	first := make(map[class]int)
+	classes := make([]int, len(p.users))
+	for u, user := range p.users {
+		classes[u] = -1
+		if len(p.deps[u]) > 0 || len(p.depGroups[u]) > 0 || len(p.dependees[u]) > 0 ||
+			p.groupOf[u] >= 0 || user.State != Drawn {
+			continue
+		}
+		c := class{prefs: fmt.Sprint(p.prefs[u]), weight: p.refusalWeights[u]}
+		if _, ok := first[c]; !ok {
+			first[c] = u
+		}
+		classes[u] = first[c]
+	}
+	return classes
+}
+
+// exact explores all states of the draw of a problem.
+type exact struct {
+	p         *Problem
+	available []int
+	// class holds the first interchangeable user of every user, or -1.
+	class []int
+	// memo holds the pass probabilities of every user, starting from an explored state.
+	memo map[string][]float64
+}
+
+// stage explores a stage of the draw, see state.drawStage.
+func (e *exact) stage(s *state) ([]float64, error) {
+	open := make([]bool, len(e.p.partitions))
+	for part := range open {
+		open[part] = true
+	}
+	return e.draw(s, open, 0, false)
+}
+
+// draw explores the next refusal of a stage. The refusal is drawn from the first
+// partition from part onwards that is open.
+// progress tells if a refusal was drawn since the cursor last started at the first partition.
+func (e *exact) draw(s *state, open []bool, part int, progress bool) ([]float64, error) {
+	for {
+		if part == len(open) {
+			if !progress {
+				// The stage is done.
+				s = s.clone()
+				if !s.nextStage() {
+					return e.passes(s), nil
+				}
+				return e.stage(s)
+			}
+			part = 0
+			progress = false
+		}
+		if open[part] && e.available[part]-s.holders[part] < s.numCandidates[part] {
+			break
+		}
+		if open[part] {
+			// We refused enough users.
+			open = slices.Clone(open)
+			open[part] = false
+		}
+		part++
+	}
+
+	key := e.key(s, open, part, progress)
+	if probs, ok := e.memo[key]; ok {
+		return probs, nil
+	}
+	if len(e.memo) >= MaxExactStates {
+		return nil, fmt.Errorf("problem is too large to compute exact probabilities: more than %d states", MaxExactStates)
+	}
+
+	// Refusing any user of a class leads to the same outcome for the class.
+	// We always refuse its first candidate, so equal states have equal keys.
+	var total float64
+	var branches []int
+	weights := make(map[int]float64)
+	for u, isCandidate := range s.candidate {
+		if !isCandidate || s.assigned[u] != part {
+			continue
+		}
+		total += e.p.refusalWeights[u]
+		if _, ok := weights[e.branch(u)]; !ok {
+			branches = append(branches, u)
+		}
+		weights[e.branch(u)] += e.p.refusalWeights[u]
+	}
+
+	probs := make([]float64, len(e.p.users))
+	for _, u := range branches {
+		next := s.clone()
+		next.refuse(u)
+		nextProbs, err := e.draw(next, open, part+1, true)
+		if err != nil {
+			return nil, err
+		}
+		weight := weights[e.branch(u)] / total
+		for v, prob := range nextProbs {
+			probs[v] += weight * prob
+		}
+	}
+
+	e.memo[key] = probs
+	return probs, nil
+}
+
+// branch returns the class of the user, or the user itself if it has no class.
+func (e *exact) branch(u int) int {
+	if c := e.class[u]; c >= 0 {
+		return c
+	}
+	return u
+}
+
+// passes returns 1 for every user with a pass in the final state, 0 otherwise.
+func (e *exact) passes(s *state) []float64 {
+	probs := make([]float64, len(e.p.users))
+	for u, part := range s.assigned {
+		if part >= 0 {
+			probs[u] = 1
+		}
+	}
+	return probs
+}
+
+// key identifies the state of the draw: everything later refusals depend on.
+func (e *exact) key(s *state, open []bool, part int, progress bool) string {
+	var key []byte
+	for u := range s.assigned {
+		key = binary.AppendVarint(key, int64(s.assigned[u]))
+		key = binary.AppendUvarint(key, uint64(s.choice[u]))
+		key = append(key, boolByte(s.candidate[u]))
+	}
+	for _, isOpen := range open {
+		key = append(key, boolByte(isOpen))
+	}
+	key = binary.AppendUvarint(key, uint64(part))
+	key = append(key, boolByte(progress))
+	return string(key)
+}
+
+func boolByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
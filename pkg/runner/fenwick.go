@@ -0,0 +1,77 @@
+package runner
+
+// fenwick is a Fenwick tree over weights, allowing to sample an index with
+// probability proportional to its weight, and to remove an index, in O(log n).
+type fenwick struct {
+	// weights holds the current weight of each index.
+	weights []float64
+	// tree holds partial sums; tree[i] covers indices (i - lowbit(i), i] 1-based.
+	tree []float64
+	// mask is the highest power of two not larger than len(weights).
+	mask int
+}
+
+func newFenwick(weights []float64) *fenwick {
+	n := len(weights)
+	f := &fenwick{
+		weights: weights,
+		tree:    make([]float64, n+1),
+	}
+	// Linear time construction.
+	for i := 1; i <= n; i++ {
+		f.tree[i] += weights[i-1]
+		if j := i + (i & -i); j <= n {
+			f.tree[j] += f.tree[i]
+		}
+	}
+	for f.mask = 1; f.mask*2 <= n; f.mask *= 2 {
+	}
+	return f
+}
+
+// remove sets the weight of index i to 0.
+func (f *fenwick) remove(i int) {
+	w := f.weights[i]
+	if w == 0 {
+		return
+	}
+	f.weights[i] = 0
+	for j := i + 1; j < len(f.tree); j += j & -j {
+		f.tree[j] -= w
+	}
+}
+
+// total returns the sum of all weights.
+func (f *fenwick) total() float64 {
+	sum := 0.0
+	for j := len(f.weights); j > 0; j -= j & -j {
+		sum += f.tree[j]
+	}
+	return sum
+}
+
+// find returns the first index whose prefix sum of weights reaches x,
+// skipping indices with weight 0. Returns -1 if all weights are 0.
+func (f *fenwick) find(x float64) int {
+	pos := 0
+	for step := f.mask; step > 0; step /= 2 {
+		if next := pos + step; next < len(f.tree) && f.tree[next] < x {
+			pos = next
+			x -= f.tree[next]
+		}
+	}
+
+	// pos is the index we look for, unless floating point errors
+	// made us land on a removed index or beyond the last index.
+	for i := pos; i < len(f.weights); i++ {
+		if f.weights[i] > 0 {
+			return i
+		}
+	}
+	for i := min(pos, len(f.weights)) - 1; i >= 0; i-- {
+		if f.weights[i] > 0 {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,135 @@
+package runner
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Graph describes the dependency graph of a problem.
+// A user has an edge to every user it depends on, through Deps or DepGroups.
+type Graph struct {
+	// Components lists the strongly connected components of more than one user,
+	// i.e. users that depend on each other through a cycle; largest first.
+	Components [][]UserID
+	// Depth is the length of the longest chain of dependencies starting at each user.
+	// Users without dependencies have depth 0; users of the same component count as one.
+	Depth map[UserID]int
+	// FanIn counts the users that depend on each user.
+	FanIn map[UserID]int
+}
+
+// Analyze computes the dependency graph of the problem.
+func (p *Problem) Analyze() *Graph {
+	n := len(p.users)
+	edges := make([][]int, n)
+	for u := range p.users {
+		edges[u] = append(edges[u], p.deps[u]...)
+		for _, g := range p.depGroups[u] {
+			edges[u] = append(edges[u], g.users...)
+		}
+		slices.Sort(edges[u])
+		edges[u] = slices.Compact(edges[u])
+	}
+
+	g := &Graph{
+		Depth: make(map[UserID]int, n),
+		FanIn: make(map[UserID]int, n),
+	}
+	for _, u := range p.users {
+		g.FanIn[u.ID] = 0
+	}
+	for u := range p.users {
+		for _, d := range edges[u] {
+			g.FanIn[p.users[d].ID]++
+		}
+	}
+
+	// Tarjan finds components in reverse topological order: every component
+	// a component depends on is found before it, so its depth is known.
+	components := tarjan(edges)
+	componentOf := make([]int, n)
+	for c, members := range components {
+		for _, u := range members {
+			componentOf[u] = c
+		}
+	}
+	depth := make([]int, len(components))
+	for c, members := range components {
+		for _, u := range members {
+			for _, d := range edges[u] {
+				if dc := componentOf[d]; dc != c {
+					depth[c] = max(depth[c], depth[dc]+1)
+				}
+			}
+		}
+		for _, u := range members {
+			g.Depth[p.users[u].ID] = depth[c]
+		}
+		if len(members) > 1 {
+			ids := p.ids(members)
+			slices.Sort(ids)
+			g.Components = append(g.Components, ids)
+		}
+	}
+	slices.SortStableFunc(g.Components, func(a, b []UserID) int {
+		return cmp.Or(
+			cmp.Compare(len(b), len(a)),
+			cmp.Compare(a[0], b[0]),
+		)
+	})
+	return g
+}
+
+// tarjan returns the strongly connected components of the graph, in reverse topological order.
+func tarjan(edges [][]int) [][]int {
+	n := len(edges)
+	index := make([]int, n)
+	low := make([]int, n)
+	onStack := make([]bool, n)
+	for u := range index {
+		index[u] = -1
+	}
+
+	var (
+		next       int
+		stack      []int
+		components [][]int
+	)
+	var visit func(u int)
+	visit = func(u int) {
+		index[u] = next
+		low[u] = next
+		next++
+		stack = append(stack, u)
+		onStack[u] = true
+
+		for _, d := range edges[u] {
+			if index[d] < 0 {
+				visit(d)
+				low[u] = min(low[u], low[d])
+			} else if onStack[d] {
+				low[u] = min(low[u], index[d])
+			}
+		}
+
+		if low[u] == index[u] {
+			var component []int
+			for {
+				v := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[v] = false
+				component = append(component, v)
+				if v == u {
+					break
+				}
+			}
+			components = append(components, component)
+		}
+	}
+	for u := range edges {
+		if index[u] < 0 {
+			visit(u)
+		}
+	}
+	return components
+}
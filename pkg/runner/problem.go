@@ -0,0 +1,241 @@
+package runner
+
+import (
+	"maps"
+	"math/rand"
+	"slices"
+)
+
+// Problem is a compiled, read-only form of the users of a draw.
+// Users are identified by dense indices, in canonical order of their IDs.
+//
+// Compiling is done once; every Run then only allocates a small state.
+// A Problem is safe for concurrent use, as long as every goroutine uses its own rand.
+type Problem struct {
+	users []User
+	index map[UserID]int
+	// grantWeights are the user weights, defaulting to 1.
+	grantWeights []float64
+	// refusalWeights is the inverse of the user weights; we draw refusals.
+	refusalWeights []float64
+
+	partitions []Partition
+	partIndex  map[Partition]int
+	// prefs holds the partition indices each user wants a pass for, in order of preference.
+	prefs [][]int
+	// firstChoice holds the users of each partition that registered for it as first choice.
+	firstChoice [][]int
+
+	// deps and depGroups only contain known users.
+	deps      [][]int
+	depGroups [][]compiledGroup
+	// dependees holds, for each user, the users that depend on it.
+	dependees [][]int
+	// groupOf is the index into groups of every user, or -1.
+	groupOf []int
+	groups  [][]int
+}
+
+type compiledGroup struct {
+	users    []int
+	required int
+}
+
+// Compile creates a problem for the given users.
+// If multiple users share an ID, the last one is used.
+// Dependencies on unknown users are ignored.
+func Compile(users []User) *Problem {
+	byID := make(map[UserID]User)
+	for _, u := range users {
+		byID[u.ID] = u
+	}
+
+	p := &Problem{
+		index:     make(map[UserID]int, len(byID)),
+		partIndex: make(map[Partition]int),
+	}
+	for _, id := range slices.Sorted(maps.Keys(byID)) {
+		p.index[id] = len(p.users)
+		p.users = append(p.users, byID[id])
+	}
+
+	partSet := make(map[Partition]bool)
+	var groupIDs []GroupID
+	for _, u := range p.users {
+		for _, part := range u.Preferences() {
+			partSet[part] = true
+		}
+		if u.Group != "" {
+			groupIDs = append(groupIDs, u.Group)
+		}
+	}
+	p.partitions = slices.Sorted(maps.Keys(partSet))
+	for i, part := range p.partitions {
+		p.partIndex[part] = i
+	}
+	p.firstChoice = make([][]int, len(p.partitions))
+
+	slices.Sort(groupIDs)
+	groupIDs = slices.Compact(groupIDs)
+	groupIndex := make(map[GroupID]int)
+	for i, g := range groupIDs {
+		groupIndex[g] = i
+	}
+	p.groups = make([][]int, len(groupIDs))
+
+	n := len(p.users)
+	p.grantWeights = make([]float64, n)
+	p.refusalWeights = make([]float64, n)
+	p.prefs = make([][]int, n)
+	p.deps = make([][]int, n)
+	p.depGroups = make([][]compiledGroup, n)
+	p.dependees = make([][]int, n)
+	p.groupOf = make([]int, n)
+	for i, u := range p.users {
+		// The interface exposes 2 = twice as probable to get a pass.
+		// However, internally, we work with refusals.
+		// So we need to set the weight to 1/2.
+		if u.Weight <= 0 {
+			p.grantWeights[i] = 1
+		} else {
+			p.grantWeights[i] = u.Weight
+		}
+		p.refusalWeights[i] = 1 / p.grantWeights[i]
+
+		for _, part := range u.Preferences() {
+			p.prefs[i] = append(p.prefs[i], p.partIndex[part])
+		}
+		p.firstChoice[p.partIndex[u.Partition]] = append(p.firstChoice[p.partIndex[u.Partition]], i)
+
+		for _, dep := range u.Deps {
+			if d, ok := p.index[dep]; ok {
+				p.deps[i] = append(p.deps[i], d)
+				p.dependees[d] = append(p.dependees[d], i)
+			}
+		}
+		for _, g := range u.DepGroups {
+			cg := compiledGroup{required: g.Required()}
+			for _, dep := range g.Users {
+				if d, ok := p.index[dep]; ok {
+					cg.users = append(cg.users, d)
+					p.dependees[d] = append(p.dependees[d], i)
+				}
+			}
+			p.depGroups[i] = append(p.depGroups[i], cg)
+		}
+
+		p.groupOf[i] = -1
+		if u.Group != "" {
+			g := groupIndex[u.Group]
+			p.groupOf[i] = g
+			p.groups[g] = append(p.groups[g], i)
+		}
+	}
+	return p
+}
+
+// Users returns the users that registered for the partition as their first choice.
+func (p *Problem) Users(partition Partition) []UserID {
+	part, ok := p.partIndex[partition]
+	if !ok {
+		return nil
+	}
+	return p.ids(p.firstChoice[part])
+}
+
+// User returns the user with the given ID.
+func (p *Problem) User(id UserID) (User, bool) {
+	i, ok := p.index[id]
+	if !ok {
+		return User{}, false
+	}
+	return p.users[i], true
+}
+
+// Partitions returns all partitions users want a pass for.
+func (p *Problem) Partitions() []Partition {
+	return slices.Clone(p.partitions)
+}
+
+func (p *Problem) ids(indices []int) []UserID {
+	ids := make([]UserID, 0, len(indices))
+	for _, i := range indices {
+		ids = append(ids, p.users[i].ID)
+	}
+	return ids
+}
+
+// dependeesOf returns the users that depend on the user with the given ID.
+func (p *Problem) dependeesOf(id UserID) []UserID {
+	i, ok := p.index[id]
+	if !ok {
+		return nil
+	}
+	return p.ids(p.dependees[i])
+}
+
+// groupMembersOf returns all users in the group of the user with the given ID,
+// including the user itself.
+func (p *Problem) groupMembersOf(id UserID) []UserID {
+	i, ok := p.index[id]
+	if !ok || p.groupOf[i] < 0 {
+		return nil
+	}
+	return p.ids(p.groups[p.groupOf[i]])
+}
+
+// Run draws the passes with the given rand.
+//
+// The draw happens in stages. In the first stage, every user is a candidate for
+// their first choice partition. Users that are refused and have fallback partitions
+// become candidates for their next fallback in the following stage.
+// A stage only hands out passes that were left over by the previous stages, so
+// users never lose a pass to users for which the partition is a fallback.
+//
+// Guaranteed users get their pass and excluded users are refused before the first stage.
+//
+// Run uses the Backward strategy.
+func (p *Problem) Run(rand *rand.Rand, availabilities []Availability) (*Solution, error) {
+	return p.RunStrategy(Backward, rand, availabilities)
+}
+
+// RunStrategy draws the passes with the given strategy and rand; see Run.
+func (p *Problem) RunStrategy(strategy Strategy, rand *rand.Rand, availabilities []Availability) (*Solution, error) {
+	return p.runStrategy(strategy, rand, availabilities, true)
+}
+
+// RunUntraced is the same as RunStrategy, but leaves out the Refusals of the solution.
+// This makes it considerably faster for simulating many draws.
+func (p *Problem) RunUntraced(strategy Strategy, rand *rand.Rand, availabilities []Availability) (*Solution, error) {
+	return p.runStrategy(strategy, rand, availabilities, false)
+}
+
+func (p *Problem) runStrategy(strategy Strategy, rand *rand.Rand, availabilities []Availability, traced bool) (*Solution, error) {
+	if rand == nil {
+		panic("rand cannot be nil")
+	}
+	s := newState(p, rand)
+	if traced {
+		s.startTrace()
+	}
+	if err := s.run(strategy, availabilities); err != nil {
+		return nil, err
+	}
+	return s.solution(), nil
+}
+
+// available returns the available passes of every partition.
+func (p *Problem) available(availabilities []Availability) []int {
+	available := make([]int, len(p.partitions))
+	for _, a := range availabilities {
+		if part, ok := p.partIndex[a.Partition]; ok {
+			available[part] = a.Available
+		}
+	}
+	return available
+}
+
+// AllUsers returns all users, in canonical order.
+func (p *Problem) AllUsers() []User {
+	return slices.Clone(p.users)
+}
@@ -0,0 +1,85 @@
+package runner
+
+import "math"
+
+// Ratio makes two partitions share a total amount of passes, while keeping
+// the ratio of passes between them within bounds. This is useful for couples dances,
+// where the ratio between leaders and followers matters more than fixed amounts.
+type Ratio struct {
+	Left  Partition
+	Right Partition
+	// Total is the amount of passes shared by Left and Right.
+	Total int
+	// Min and Max bound the ratio of Left passes to Right passes.
+	Min float64
+	Max float64
+}
+
+// Balance returns availabilities where the passes of partitions constrained by a ratio
+// are replaced with the best split of the ratio's total.
+//
+// The given availabilities of constrained partitions act as an upper bound.
+// The best split hands out as many passes as possible to the registered users given by demand.
+// If there are multiple, the split closest to the ratio of demand is chosen.
+// If no split keeps the ratio within bounds, e.g. because nobody registered for one side,
+// the ratio is ignored, and both sides get as many passes as possible.
+func Balance(availabilities []Availability, ratios []Ratio, demand map[Partition]int) []Availability {
+	byPartition := make(map[Partition]int)
+	for _, a := range availabilities {
+		byPartition[a.Partition] = a.Available
+	}
+
+	for _, ratio := range ratios {
+		left, right := ratio.split(byPartition[ratio.Left], byPartition[ratio.Right], demand[ratio.Left], demand[ratio.Right])
+		byPartition[ratio.Left] = left
+		byPartition[ratio.Right] = right
+	}
+
+	balanced := make([]Availability, 0, len(availabilities))
+	for _, a := range availabilities {
+		balanced = append(balanced, Availability{
+			Partition: a.Partition,
+			Available: byPartition[a.Partition],
+		})
+	}
+	return balanced
+}
+
+// split returns the best amount of passes for Left and Right.
+func (r Ratio) split(leftCap, rightCap, leftDemand, rightDemand int) (int, int) {
+	const eps = 1e-9
+
+	maxLeft := min(leftCap, leftDemand, r.Total)
+	maxRight := min(rightCap, rightDemand, r.Total)
+
+	target := r.Min
+	if rightDemand > 0 {
+		target = math.Min(math.Max(float64(leftDemand)/float64(rightDemand), r.Min), r.Max)
+	}
+
+	bestLeft, bestRight := 0, 0
+	bestDist := math.Inf(1)
+	consider := func(left, right int) {
+		dist := math.Abs(float64(left) - target*float64(right))
+		if left+right > bestLeft+bestRight || left+right == bestLeft+bestRight && dist < bestDist {
+			bestLeft, bestRight, bestDist = left, right, dist
+		}
+	}
+	for left := 1; left <= maxLeft; left++ {
+		lo := int(math.Ceil(float64(left)/r.Max - eps))
+		hi := min(int(math.Floor(float64(left)/r.Min+eps)), maxRight, r.Total-left)
+		if lo > hi {
+			continue
+		}
+		consider(left, hi)
+	}
+	if bestLeft+bestRight > 0 {
+		return bestLeft, bestRight
+	}
+
+	// No split keeps the ratio; ignore it rather than leaving passes unused.
+	for left := 0; left <= maxLeft; left++ {
+		consider(left, min(maxRight, r.Total-left))
+	}
+	return bestLeft, bestRight
+}
@@ -0,0 +1,312 @@
+package runner
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+)
+
+// CancelPolicy decides what happens to users that depend on a canceled user.
+type CancelPolicy string
+
+const (
+	// RefuseDependees takes away the pass of every user whose dependencies can no longer
+	// be met because of a canceled user.
+	RefuseDependees CancelPolicy = "refuse"
+	// KeepDependees lets users that depend on a canceled user keep their pass.
+	KeepDependees CancelPolicy = "keep"
+)
+
+// ChangeSet lists the users that gained or lost a pass compared to a previous solution.
+type ChangeSet struct {
+	Gained map[Partition][]UserID
+	Lost   map[Partition][]UserID
+}
+
+// Recycle frees the passes of the canceled users and hands them to the waitlisted users of prev.
+// Users that depend on a canceled user are handled according to policy.
+//
+// Waitlisted users are promoted in waitlist order, round robin over all partitions.
+// A waitlisted user whose dependencies do not have a pass is only promoted together
+// with these dependencies, if there is room in all involved partitions.
+func (r *Runner) Recycle(availabilities []Availability, prev *Solution, canceled []UserID, policy CancelPolicy) (*Solution, *ChangeSet, error) {
+	return r.problem.Recycle(availabilities, prev, canceled, policy)
+}
+
+// Recycle is the same as Runner.Recycle.
+func (pr *Problem) Recycle(availabilities []Availability, prev *Solution, canceled []UserID, policy CancelPolicy) (*Solution, *ChangeSet, error) {
+	if policy != RefuseDependees && policy != KeepDependees {
+		return nil, nil, fmt.Errorf("unknown cancel policy %q", policy)
+	}
+
+	holders := make(map[UserID]Partition)
+	for part, partPass := range prev.Passes {
+		for _, id := range partPass {
+			u, ok := pr.User(id)
+			if !ok || !slices.Contains(u.Preferences(), part) {
+				return nil, nil, fmt.Errorf("user %s of partition %s in solution is not registered for that partition", id, part)
+			}
+			if other, ok := holders[id]; ok {
+				return nil, nil, fmt.Errorf("user %s has a pass for partitions %s and %s in solution", id, other, part)
+			}
+			holders[id] = part
+		}
+	}
+
+	isCanceled := make(map[UserID]bool)
+	for _, id := range canceled {
+		if _, ok := pr.User(id); !ok {
+			return nil, nil, fmt.Errorf("canceled user %s is not registered", id)
+		}
+		isCanceled[id] = true
+	}
+
+	changes := &ChangeSet{
+		Gained: make(map[Partition][]UserID),
+		Lost:   make(map[Partition][]UserID),
+	}
+
+	// Take away passes of canceled users, and, depending on the policy, their dependees.
+	lost := slices.Sorted(maps.Keys(isCanceled))
+	handled := make(map[UserID]bool)
+	for len(lost) > 0 {
+		id := lost[0]
+		lost = lost[1:]
+		if handled[id] {
+			continue
+		}
+		handled[id] = true
+		part, isHolder := holders[id]
+		if !isHolder && !isCanceled[id] {
+			continue
+		}
+		if isHolder {
+			delete(holders, id)
+			changes.Lost[part] = append(changes.Lost[part], id)
+		}
+		if policy == RefuseDependees {
+			// Dependees only lose their pass if their dependencies can no longer be met,
+			// e.g. users of a dependency group keep it while enough of the group hold a pass.
+			for _, d := range pr.dependeesOf(id) {
+				if !pr.satisfiedBy(d, holders) {
+					lost = append(lost, d)
+				}
+			}
+			lost = append(lost, pr.groupMembersOf(id)...)
+		}
+	}
+
+	p := promoter{
+		problem:  pr,
+		holders:  holders,
+		canceled: isCanceled,
+		free:     make(map[Partition]int),
+		changes:  changes,
+	}
+	for _, a := range availabilities {
+		p.free[a.Partition] = a.Available
+	}
+	for _, part := range holders {
+		p.free[part]--
+	}
+	p.promote(prev.Waitlist)
+
+	passes := make(map[Partition][]UserID)
+	for _, part := range pr.partitions {
+		passes[part] = []UserID{}
+	}
+	for id, part := range holders {
+		passes[part] = append(passes[part], id)
+	}
+	for part := range passes {
+		slices.Sort(passes[part])
+	}
+	for part := range changes.Gained {
+		slices.Sort(changes.Gained[part])
+	}
+	for part := range changes.Lost {
+		slices.Sort(changes.Lost[part])
+	}
+
+	waitlist := make(map[Partition][]UserID)
+	for part, wl := range prev.Waitlist {
+		waitlist[part] = slices.DeleteFunc(slices.Clone(wl), func(id UserID) bool {
+			_, isHolder := holders[id]
+			return isHolder || isCanceled[id]
+		})
+	}
+
+	return &Solution{
+		Passes:     passes,
+		Waitlist:   waitlist,
+		TicketSeed: prev.TicketSeed,
+		Refusals:   prev.Refusals,
+	}, changes, nil
+}
+
+// satisfiedBy returns true if the dependencies and the group of the user are
+// satisfied by the holders of a pass; see state.satisfiable.
+func (pr *Problem) satisfiedBy(id UserID, holders map[UserID]Partition) bool {
+	hasPass := func(u int) bool {
+		_, ok := holders[pr.users[u].ID]
+		return ok
+	}
+	u := pr.index[id]
+	for _, dep := range pr.deps[u] {
+		if !hasPass(dep) {
+			return false
+		}
+	}
+	for _, g := range pr.depGroups[u] {
+		have := 0
+		for _, dep := range g.users {
+			if hasPass(dep) {
+				have++
+			}
+		}
+		if have < g.required {
+			return false
+		}
+	}
+	if g := pr.groupOf[u]; g >= 0 {
+		for _, m := range pr.groups[g] {
+			if !hasPass(m) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// promoter hands out free passes to waitlisted users.
+type promoter struct {
+	problem  *Problem
+	holders  map[UserID]Partition
+	canceled map[UserID]bool
+	free     map[Partition]int
+	changes  *ChangeSet
+}
+
+// promote admits waitlisted users round robin over all partitions until
+// no more users can be admitted.
+func (p *promoter) promote(waitlist map[Partition][]UserID) {
+	madeProgress := true
+	for madeProgress {
+		madeProgress = false
+		for _, part := range slices.Sorted(maps.Keys(waitlist)) {
+			if p.free[part] <= 0 {
+				continue
+			}
+			for _, id := range waitlist[part] {
+				if p.admit(id, part) {
+					madeProgress = true
+					break
+				}
+			}
+		}
+	}
+}
+
+func (p *promoter) hasPass(id UserID) bool {
+	_, ok := p.holders[id]
+	return ok
+}
+
+// admit gives the user a pass for the partition. The other users of its group,
+// and all of their transitive dependencies without a pass, are admitted as well,
+// each to the first of their preferred partitions with room.
+// For dependency groups, just enough users are admitted to satisfy the group.
+// Returns false if that is not possible.
+func (p *promoter) admit(id UserID, part Partition) bool {
+	if p.hasPass(id) {
+		return false
+	}
+
+	closure := make(map[UserID]Partition)
+	need := make(map[Partition]int)
+	place := func(u UserID, part Partition) {
+		closure[u] = part
+		need[part]++
+	}
+
+	todo := []UserID{id}
+	for len(todo) > 0 {
+		u := todo[0]
+		todo = todo[1:]
+		if _, ok := closure[u]; ok || p.hasPass(u) {
+			continue
+		}
+		user, ok := p.problem.User(u)
+		if !ok || p.canceled[u] || user.State == Excluded {
+			return false
+		}
+
+		if u == id {
+			place(u, part)
+		} else {
+			placed := false
+			for _, pref := range user.Preferences() {
+				if p.free[pref]-need[pref] > 0 {
+					place(u, pref)
+					placed = true
+					break
+				}
+			}
+			if !placed {
+				return false
+			}
+		}
+
+		todo = append(todo, user.Deps...)
+		todo = append(todo, p.problem.groupMembersOf(u)...)
+
+		for _, g := range user.DepGroups {
+			have := 0
+			var missing []UserID
+			for _, dep := range g.Users {
+				_, inClosure := closure[dep]
+				if p.hasPass(dep) || inClosure || slices.Contains(todo, dep) {
+					have++
+				} else if d, ok := p.problem.User(dep); ok && !p.canceled[dep] && d.State != Excluded {
+					missing = append(missing, dep)
+				}
+			}
+			if have >= g.Required() {
+				continue
+			}
+			if have+len(missing) < g.Required() {
+				return false
+			}
+			// Admit the missing users in a canonical order.
+			slices.Sort(missing)
+			todo = append(todo, missing[:g.Required()-have]...)
+		}
+	}
+
+	for part, n := range need {
+		if p.free[part] < n {
+			return false
+		}
+	}
+
+	for _, u := range slices.Sorted(maps.Keys(closure)) {
+		part := closure[u]
+		p.holders[u] = part
+		p.free[part]--
+		p.changes.Gained[part] = append(p.changes.Gained[part], u)
+	}
+	return true
+}
+
+// FillUp hands out passes that were left unused because refusals cascaded into
+// partitions that ended up with fewer users than passes.
+// Refused users are re-admitted in waitlist order, so the fill-up is as fair as the draw.
+// Nobody loses their pass.
+func (r *Runner) FillUp(availabilities []Availability, s *Solution) (*Solution, *ChangeSet, error) {
+	return r.problem.FillUp(availabilities, s)
+}
+
+// FillUp is the same as Runner.FillUp.
+func (pr *Problem) FillUp(availabilities []Availability, s *Solution) (*Solution, *ChangeSet, error) {
+	return pr.Recycle(availabilities, s, nil, KeepDependees)
+}
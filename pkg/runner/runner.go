@@ -3,49 +3,110 @@ package runner
 import (
 	"errors"
 	"fmt"
-	"maps"
 	"math/rand"
-	"slices"
 	"strings"
-
-	"github.com/wchresta/passdraw/pkg/log"
 )
 
 type UserID string
 
 type Partition string
 
+// GroupID identifies a group of users that are either admitted or refused as a whole.
+type GroupID string
+
 type User struct {
 	ID        UserID
 	Partition Partition
-	Deps      []UserID
+
+	// Deps must all get a pass for this user to get a pass.
+	Deps []UserID
+	// DepGroups must all be satisfied for this user to get a pass.
+	DepGroups []DepGroup
+	// Group, if not empty, makes this user get a pass if and only if
+	// all other users of the same group get a pass.
+	// Groups can span partitions. Either all or no members of a group must be Guaranteed.
+	Group GroupID
+
+	// Fallbacks are partitions, in order of preference, for which this user
+	// becomes a candidate if refused for Partition.
+	// A user never gets more than one pass.
+	Fallbacks []Partition
+
+	// State can take the user out of the random draw.
+	State State
 
 	// Weight can change how likely it is for a user to get a pass.
 	// A number below 1 reduces changes to get a pass, number above 1 increase them.
-	// If 0; defaults to 1.
-	// Set to a number below 0 to guarantee a refusal.
+	// If 0 or below; defaults to 1.
+	// Use State to guarantee a pass or a refusal.
 	Weight float64
 }
 
+// State decides whether a user takes part in the random draw.
+type State string
+
+const (
+	// Drawn users take part in the random draw.
+	Drawn State = ""
+	// Guaranteed users always get a pass, e.g. teachers and volunteers.
+	// Their passes are taken from the availability before the draw,
+	// and their dependencies are not enforced.
+	Guaranteed State = "guaranteed"
+	// Excluded users never get a pass, e.g. banned users.
+	// They are refused before the draw, together with the users depending on them.
+	Excluded State = "excluded"
+)
+
+// Preferences returns the partitions the user wants a pass for, in order of preference.
+func (u User) Preferences() []Partition {
+	return append([]Partition{u.Partition}, u.Fallbacks...)
+}
+
+// DepGroup is satisfied if at least Min of its Users get a pass.
+// This allows expressing any-of (Min 1) and k-of-n dependencies.
+type DepGroup struct {
+	Users []UserID
+	// Min is the amount of Users that need to get a pass.
+	// If 0; defaults to 1.
+	Min int `json:",omitempty"`
+}
+
+// Required returns the amount of users that need to get a pass.
+func (g DepGroup) Required() int {
+	if g.Min <= 0 {
+		return 1
+	}
+	return g.Min
+}
+
 type Availability struct {
 	Partition Partition
 	Available int
 }
 
+// Runner draws passes for a fixed set of users.
+// Runners are not safe for concurrent use; use a Problem for that.
 type Runner struct {
-	// Managed by New
-	userByID map[UserID]User
+	problem  *Problem
 	rand     *rand.Rand
-
-	// Managed by reset/Run
-	usersInPartition map[Partition][]UserID
-	dependees        map[UserID][]UserID
-	candidates       map[Partition]map[UserID]bool
-	candidateWeights map[Partition]float64
+	strategy Strategy
 }
 
 type Solution struct {
 	Passes map[Partition][]UserID
+
+	// Waitlist ranks the users of each partition that did not get a pass.
+	// Users that were refused later in the draw come first, so the
+	// waitlist is as fair as the draw itself.
+	Waitlist map[Partition][]UserID
+
+	// TicketSeed is the seed the tickets of the users were derived from,
+	// if the Tickets strategy was used; see Problem.Tickets.
+	TicketSeed int64 `json:",omitempty"`
+
+	// Refusals explains every refusal of the draw, by user, in the order they happened.
+	// A user with fallbacks can be refused once for every partition they were a candidate for.
+	Refusals map[UserID][]Refusal `json:",omitempty"`
 }
 
 func New(users []User) *Runner {
@@ -56,156 +117,36 @@ func NewWithRand(users []User, rand *rand.Rand) *Runner {
 	if rand == nil {
 		panic("rand cannot be nil")
 	}
-	userMap := make(map[UserID]User)
-	for _, u := range users {
-		// The interface exposes 2 = twice as probable to get a pass.
-		// However, internally, we work with refusals.
-		// So we need to set the weight to 1/2.
-		if u.Weight <= 0 {
-			u.Weight = 1
-		} else {
-			u.Weight = 1 / u.Weight
-		}
-		userMap[u.ID] = u
-	}
-
 	return &Runner{
-		userByID: userMap,
+		problem:  Compile(users),
 		rand:     rand,
+		strategy: Backward,
 	}
 }
 
-// reset resets the state to before any calculations.
-// This makes testing a lot easier.
-func (r *Runner) reset() {
-	r.usersInPartition = make(map[Partition][]UserID)
-	r.candidateWeights = make(map[Partition]float64)
-	r.dependees = make(map[UserID][]UserID)
-	r.candidates = make(map[Partition]map[UserID]bool)
-
-	for _, u := range r.userByID {
-		r.usersInPartition[u.Partition] = append(r.usersInPartition[u.Partition], u.ID)
-		r.candidateWeights[u.Partition] += u.Weight
-
-		for _, dep := range u.Deps {
-			r.dependees[dep] = append(r.dependees[dep], u.ID)
-		}
+// SetStrategy changes the strategy of future runs; defaults to Backward.
+func (r *Runner) SetStrategy(strategy Strategy) {
+	r.strategy = strategy
+}
 
-		if _, ok := r.candidates[u.Partition]; !ok {
-			r.candidates[u.Partition] = make(map[UserID]bool)
-		}
-		r.candidates[u.Partition][u.ID] = true
-	}
+// Problem returns the compiled problem of the runner.
+func (r *Runner) Problem() *Problem {
+	return r.problem
 }
 
+// Users returns the users that registered for the partition as their first choice.
 func (r *Runner) Users(partition Partition) []UserID {
-	return slices.Clone(r.usersInPartition[partition])
+	return r.problem.Users(partition)
 }
 
 func (r *Runner) User(id UserID) User {
-	return r.userByID[id]
-}
-
-func (r *Runner) IsRefused(id UserID) bool {
-	u := r.User(id)
-	cand, ok := r.candidates[u.Partition][id]
-	if ok {
-		return !cand
-	}
-	return true
-}
-
-// Mark user as refused without propagating the refusal.
-func (r *Runner) shallowRefuse(id UserID) {
-	u := r.User(id)
-	delete(r.candidates[u.Partition], u.ID)
-	r.candidateWeights[u.Partition] -= u.Weight
-}
-
-// refused refuses the user with the given id, and all users that depend on it.
-// Returns true if any user was newly refused.
-func (r *Runner) refuse(id UserID) bool {
-	if r.IsRefused(id) {
-		// Already refused
-		return false
-	}
-
-	r.shallowRefuse(id)
-	for _, d := range r.dependees[id] {
-		r.refuse(d)
-	}
-	return true
+	u, _ := r.problem.User(id)
+	return u
 }
 
+// Run draws the passes with the strategy of the runner; see Problem.Run.
 func (r *Runner) Run(availabilities []Availability) (*Solution, error) {
-	r.reset()
-
-	availabilitiesByPartition := make(map[Partition]Availability)
-	for _, a := range availabilities {
-		availabilitiesByPartition[a.Partition] = a
-	}
-
-	partitionNeedsRefusals := make(map[Partition]bool)
-	for partName := range r.usersInPartition {
-		partitionNeedsRefusals[partName] = true
-	}
-
-	madeProgress := true
-	for madeProgress {
-		madeProgress = false
-
-	PartitionLoop:
-		for partName, isOpen := range partitionNeedsRefusals {
-			if !isOpen {
-				continue
-			}
-
-			av := availabilitiesByPartition[partName]
-			partUsers := r.usersInPartition[partName]
-
-			// Check if this partition is still open.
-			// We need to check here, because other partitions might
-			// have refused enough users here to close it.
-			// Users that are not refused get a pass.
-			if av.Available >= len(r.candidates[partName]) {
-				// We refused enough users
-				partitionNeedsRefusals[partName] = false
-				continue
-			}
-
-			// Find next refusal
-			refusalVal := r.rand.Float64() * r.candidateWeights[partName]
-			// Find the refused user
-			localWeightSum := 0.0
-			for u := range r.candidates[partName] {
-				localWeightSum += r.User(u).Weight
-				if localWeightSum < refusalVal {
-					continue
-				}
-
-				// u is the user to be refused!
-				// Swap the current user to the end of the pool and then shrink the pool.
-				if r.refuse(u) {
-					// Only if the current user is not already refused we continue.
-					// Other
-					madeProgress = true
-					continue PartitionLoop
-				}
-			}
-
-			// Did not break; so we run out of users to refuse.
-			log.Warningf("Refused all %d possible users for partition %s\n", len(partUsers), partName)
-			partitionNeedsRefusals[partName] = false
-		}
-	}
-
-	passes := make(map[Partition][]UserID)
-	for partName, cand := range r.candidates {
-		passes[partName] = slices.Sorted(maps.Keys(cand))
-	}
-	return &Solution{
-		Passes: passes,
-	}, nil
+	return r.problem.RunStrategy(r.strategy, r.rand, availabilities)
 }
 
 func UserFromString(s string) (*User, error) {
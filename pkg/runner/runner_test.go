@@ -7,7 +7,9 @@ import (
 	"maps"
 	"math"
 	"math/rand"
+	"reflect"
 	"slices"
+	"sync"
 	"testing"
 
 	"github.com/wchresta/passdraw/pkg/runner"
@@ -165,6 +167,1183 @@ func TestRun_Weights(t *testing.T) {
 	}
 }
 
+func TestRun_ReproducibleWithSeed(t *testing.T) {
+	var users []runner.User
+	users = append(users, mkFreeUsers("Left", "FreeL", 30)...)
+	users = append(users, mkFreeUsers("Right", "FreeR", 30)...)
+	for i := range 10 {
+		users = append(users, mkUserCouple("Left", "Right", fmt.Sprintf("Couple%d", i))...)
+	}
+	availability := []runner.Availability{
+		{Partition: "Left", Available: 20},
+		{Partition: "Right", Available: 25},
+	}
+
+	var want *runner.Solution
+	for i := range 10 {
+		// The order in which users are given must not matter.
+		shuffled := slices.Clone(users)
+		rand.New(rand.NewSource(int64(i))).Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+
+		r := runner.NewWithRand(shuffled, rand.New(rand.NewSource(5544332211)))
+		got, err := r.Run(availability)
+		if err != nil {
+			t.Fatalf("Run failed unexpectedly: %s", err)
+		}
+		if want == nil {
+			want = got
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Run with same seed produced different solutions: got %v, want %v", got, want)
+		}
+	}
+}
+
+func TestProblem_ConcurrentRuns(t *testing.T) {
+	var users []runner.User
+	users = append(users, mkFreeUsers("Left", "FreeL", 50)...)
+	users = append(users, mkFreeUsers("Right", "FreeR", 50)...)
+	for i := range 20 {
+		users = append(users, mkUserCouple("Left", "Right", fmt.Sprintf("Couple%d", i))...)
+	}
+	availability := []runner.Availability{
+		{Partition: "Left", Available: 40},
+		{Partition: "Right", Available: 45},
+	}
+	p := runner.Compile(users)
+
+	const numRuns = 16
+	want := make([]*runner.Solution, numRuns)
+	for i := range numRuns {
+		s, err := p.Run(rand.New(rand.NewSource(int64(i))), availability)
+		if err != nil {
+			t.Fatalf("Run failed unexpectedly: %s", err)
+		}
+		want[i] = s
+	}
+
+	got := make([]*runner.Solution, numRuns)
+	var wg sync.WaitGroup
+	for i := range numRuns {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := p.Run(rand.New(rand.NewSource(int64(i))), availability)
+			if err != nil {
+				t.Errorf("Run failed unexpectedly: %s", err)
+			}
+			got[i] = s
+		}()
+	}
+	wg.Wait()
+
+	for i := range numRuns {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("Concurrent run %d produced a different solution: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestRun_Waitlist(t *testing.T) {
+	users := mkFreeUsers("Test", "Free", 10)
+	users = append(users, mkUserCouple("Test", "Other", "Couple")...)
+	r := runner.NewWithRand(users, rand.New(rand.NewSource(5544332211)))
+	availability := []runner.Availability{
+		{Partition: "Test", Available: 5},
+		{Partition: "Other", Available: 1},
+	}
+
+	runCount := 20000
+	firstOnWaitlist := make(map[runner.UserID]int)
+	for i := 0; i < runCount; i++ {
+		solution, err := r.Run(availability)
+		if err != nil {
+			t.Fatalf("Run failed unexpectedly: %s", err)
+		}
+
+		// Every user either gets a pass or is on the waitlist.
+		seen := make(map[runner.UserID]int)
+		for _, u := range solution.Passes["Test"] {
+			seen[u]++
+		}
+		for _, u := range solution.Waitlist["Test"] {
+			seen[u]++
+		}
+		if len(seen) != 11 {
+			t.Fatalf("Passes and waitlist do not cover all users: %v", seen)
+		}
+		for u, n := range seen {
+			if n != 1 {
+				t.Fatalf("User %s is %d times in passes or waitlist", u, n)
+			}
+		}
+
+		firstOnWaitlist[solution.Waitlist["Test"][0]]++
+	}
+
+	// All users in the partition have the same constraints, so they must
+	// have the same chance to be first on the waitlist.
+	allowDelta := 0.02
+	for u, n := range sortedKeys(firstOnWaitlist) {
+		prob := float64(n) / float64(runCount)
+		if diff := math.Abs(prob - 1.0/11); diff > allowDelta {
+			t.Errorf("Unexpected probability to be first on waitlist: user=%s got %f, want %f, diff: %f", u, prob, 1.0/11, diff)
+		}
+	}
+}
+
+func TestRun_DepGroups(t *testing.T) {
+	users := mkFreeUsers("Test", "Free", 20)
+	anyOf := []runner.UserID{"Free0", "Free1"}
+	twoOf := []runner.UserID{"Free2", "Free3", "Free4"}
+	users = append(users,
+		runner.User{Partition: "Test", ID: "AllOf", Deps: anyOf},
+		runner.User{Partition: "Test", ID: "AnyOf1", DepGroups: []runner.DepGroup{{Users: anyOf}}},
+		runner.User{Partition: "Test", ID: "AnyOf2", DepGroups: []runner.DepGroup{{Users: anyOf, Min: 1}}},
+		runner.User{Partition: "Test", ID: "TwoOf1", DepGroups: []runner.DepGroup{{Users: twoOf, Min: 2}}},
+		runner.User{Partition: "Test", ID: "TwoOf2", DepGroups: []runner.DepGroup{{Users: twoOf, Min: 2}}},
+	)
+	userByID := make(map[runner.UserID]runner.User)
+	for _, u := range users {
+		userByID[u.ID] = u
+	}
+
+	r := runner.NewWithRand(users, rand.New(rand.NewSource(5544332211)))
+	availability := []runner.Availability{{Partition: "Test", Available: 12}}
+
+	runCount := 20000
+	passes := make(map[runner.UserID]int)
+	for i := 0; i < runCount; i++ {
+		solution, err := r.Run(availability)
+		if err != nil {
+			t.Fatalf("Run failed unexpectedly: %s", err)
+		}
+
+		hasPass := make(map[runner.UserID]bool)
+		for _, u := range solution.Passes["Test"] {
+			hasPass[u] = true
+			passes[u]++
+		}
+		// Every user with a pass has their dependencies satisfied.
+		for u := range hasPass {
+			for _, g := range userByID[u].DepGroups {
+				have := 0
+				for _, dep := range g.Users {
+					if hasPass[dep] {
+						have++
+					}
+				}
+				if have < g.Required() {
+					t.Fatalf("User %s got a pass, but only %d of %v got a pass", u, have, g.Users)
+				}
+			}
+		}
+	}
+
+	prob := func(u runner.UserID) float64 {
+		return float64(passes[u]) / float64(runCount)
+	}
+	allowDelta := 0.02
+
+	// Users without constraints have at least probability n/m.
+	for i := range 20 {
+		u := runner.UserID(fmt.Sprintf("Free%d", i))
+		if want := 12.0 / 25.0; prob(u) < want-allowDelta {
+			t.Errorf("Free user has too low probability: user=%s got %f, want at least %f", u, prob(u), want)
+		}
+	}
+
+	// Users with the same constraints have the same probability.
+	for _, pair := range [][2]runner.UserID{{"AnyOf1", "AnyOf2"}, {"TwoOf1", "TwoOf2"}} {
+		if diff := math.Abs(prob(pair[0]) - prob(pair[1])); diff > allowDelta {
+			t.Errorf("Users with same constraints have different probabilities: %s got %f, %s got %f", pair[0], prob(pair[0]), pair[1], prob(pair[1]))
+		}
+	}
+
+	// Any-of is a weaker constraint than all-of.
+	if prob("AnyOf1") <= prob("AllOf") {
+		t.Errorf("Any-of user has lower probability than all-of user: got %f <= %f", prob("AnyOf1"), prob("AllOf"))
+	}
+}
+
+func TestRun_Groups(t *testing.T) {
+	users := mkFreeUsers("Left", "FreeL", 20)
+	users = append(users, mkFreeUsers("Right", "FreeR", 20)...)
+	var crew []runner.UserID
+	for i := range 6 {
+		part := runner.Partition("Left")
+		if i%2 == 1 {
+			part = "Right"
+		}
+		id := runner.UserID(fmt.Sprintf("Crew%d", i))
+		crew = append(crew, id)
+		users = append(users, runner.User{Partition: part, ID: id, Group: "crew"})
+	}
+
+	r := runner.NewWithRand(users, rand.New(rand.NewSource(5544332211)))
+	availability := []runner.Availability{
+		{Partition: "Left", Available: 15},
+		{Partition: "Right", Available: 15},
+	}
+
+	runCount := 5000
+	crewPasses := 0
+	for i := 0; i < runCount; i++ {
+		solution, err := r.Run(availability)
+		if err != nil {
+			t.Fatalf("Run failed unexpectedly: %s", err)
+		}
+
+		hasPass := make(map[runner.UserID]bool)
+		for _, partPass := range solution.Passes {
+			for _, u := range partPass {
+				hasPass[u] = true
+			}
+		}
+		admitted := 0
+		for _, u := range crew {
+			if hasPass[u] {
+				admitted++
+			}
+		}
+		if admitted != 0 && admitted != len(crew) {
+			t.Fatalf("Group was only partially admitted: %d of %d members got a pass", admitted, len(crew))
+		}
+		if admitted > 0 {
+			crewPasses++
+		}
+	}
+
+	if crewPasses == 0 || crewPasses == runCount {
+		t.Errorf("Group got a pass in %d of %d runs, want some but not all", crewPasses, runCount)
+	}
+}
+
+func TestRun_Fallbacks(t *testing.T) {
+	users := mkFreeUsers("Part", "Part", 10)
+	for i := range 20 {
+		u := mkUser("Full", fmt.Sprintf("Full%02d", i))
+		if i < 10 {
+			u.Fallbacks = []runner.Partition{"Part"}
+		}
+		users = append(users, u)
+	}
+
+	r := runner.NewWithRand(users, rand.New(rand.NewSource(5544332211)))
+	availability := []runner.Availability{
+		{Partition: "Full", Available: 10},
+		{Partition: "Part", Available: 12},
+	}
+
+	runCount := 20000
+	passes := make(map[runner.UserID]int)
+	for i := 0; i < runCount; i++ {
+		solution, err := r.Run(availability)
+		if err != nil {
+			t.Fatalf("Run failed unexpectedly: %s", err)
+		}
+		hasPass := make(map[runner.UserID]bool)
+		for part, partPass := range solution.Passes {
+			if n := len(partPass); n > 10 && part == "Full" || n > 12 {
+				t.Fatalf("Handed out %d passes for partition %s", n, part)
+			}
+			for _, u := range partPass {
+				if hasPass[u] {
+					t.Fatalf("User %s got more than one pass", u)
+				}
+				hasPass[u] = true
+				passes[u]++
+			}
+		}
+	}
+
+	allowDelta := 0.02
+	for u, n := range sortedKeys(passes) {
+		prob := float64(n) / float64(runCount)
+		var want float64
+		switch {
+		case u[:4] == "Part":
+			// Users of Part are not affected by users falling back to Part.
+			want = 1.0
+		case u < "Full10":
+			// Half of the fallback users are refused for Full, and get one of the two leftover passes.
+			if prob <= 0.5+allowDelta {
+				t.Errorf("Fallback user has too low probability: user=%s got %f, want more than %f", u, prob, 0.5)
+			}
+			continue
+		default:
+			want = 0.5
+		}
+		if diff := math.Abs(prob - want); diff > allowDelta {
+			t.Errorf("Run produced unexpected probabilities: user=%s got %f, want %f, diff: %f", u, prob, want, diff)
+		}
+	}
+}
+
+func TestRun_States(t *testing.T) {
+	users := mkFreeUsers("Test", "Free", 10)
+	users = append(users,
+		runner.User{Partition: "Test", ID: "Teacher1", State: runner.Guaranteed},
+		runner.User{Partition: "Test", ID: "Teacher2", State: runner.Guaranteed, Deps: []runner.UserID{"Banned"}},
+		runner.User{Partition: "Test", ID: "Banned", State: runner.Excluded},
+		mkUser("Test", "Friend", "Banned"),
+	)
+	r := runner.NewWithRand(users, rand.New(rand.NewSource(5544332211)))
+
+	availability := []runner.Availability{{Partition: "Test", Available: 7}}
+	probs := runStats(t, r, availability, 10000)["Test"]
+	for u, prob := range sortedKeys(probs) {
+		want := 5.0 / 10.0
+		switch u {
+		case "Teacher1", "Teacher2":
+			want = 1
+		case "Banned", "Friend":
+			want = 0
+		}
+		if diff := math.Abs(prob - want); diff > 0.02 {
+			t.Errorf("Run produced unexpected probabilities: user=%s got %f, want %f, diff: %f", u, prob, want, diff)
+		}
+	}
+
+	solution, err := r.Run(availability)
+	if err != nil {
+		t.Fatalf("Run failed unexpectedly: %s", err)
+	}
+	for _, u := range solution.Waitlist["Test"] {
+		if u == "Banned" {
+			t.Errorf("Excluded user is on the waitlist")
+		}
+	}
+
+	if _, err := r.Run([]runner.Availability{{Partition: "Test", Available: 1}}); err == nil {
+		t.Errorf("Run succeeded with more guaranteed users than passes")
+	}
+}
+
+func TestRun_Refusals(t *testing.T) {
+	users := mkFreeUsers("Test", "Free", 6)
+	users = append(users,
+		runner.User{Partition: "Test", ID: "Banned", State: runner.Excluded},
+		mkUser("Test", "Friend", "Banned"),
+		mkUser("Test", "A"),
+		mkUser("Test", "B", "A"),
+		runner.User{Partition: "Test", ID: "C", Group: "crew", Deps: []runner.UserID{"B"}},
+		runner.User{Partition: "Test", ID: "D", Group: "crew"},
+		runner.User{Partition: "Full", ID: "E", Fallbacks: []runner.Partition{"Test"}},
+	)
+	problem := runner.Compile(users)
+	availability := []runner.Availability{
+		{Partition: "Test", Available: 3},
+		{Partition: "Full", Available: 0},
+	}
+
+	for seed := range int64(200) {
+		solution, err := problem.Run(rand.New(rand.NewSource(seed)), availability)
+		if err != nil {
+			t.Fatalf("Run failed unexpectedly: %s", err)
+		}
+
+		untraced, err := problem.RunUntraced(runner.Backward, rand.New(rand.NewSource(seed)), availability)
+		if err != nil {
+			t.Fatalf("RunUntraced failed unexpectedly: %s", err)
+		}
+		if untraced.Refusals != nil {
+			t.Errorf("RunUntraced returned refusals")
+		}
+		untraced.Refusals = solution.Refusals
+		if !reflect.DeepEqual(solution, untraced) {
+			t.Fatalf("RunUntraced drew a different solution than Run for seed %d", seed)
+		}
+
+		hasPass := make(map[runner.UserID]bool)
+		for _, partPass := range solution.Passes {
+			for _, u := range partPass {
+				hasPass[u] = true
+			}
+		}
+		for _, u := range users {
+			refusals := solution.Refusals[u.ID]
+			if !hasPass[u.ID] && len(refusals) == 0 {
+				t.Errorf("User %s did not get a pass, but has no refusals", u.ID)
+			}
+			for _, r := range refusals {
+				if r.Cascaded() != (r.Reason == runner.ReasonDependency || r.Reason == runner.ReasonGroup) {
+					t.Errorf("User %s was refused for reason %s, but has chain %v", u.ID, r.Reason, r.Chain)
+				}
+				if r.Reason == runner.ReasonDrawn && r.Round == 0 || r.Reason == runner.ReasonExcluded && r.Round != 0 {
+					t.Errorf("User %s was refused for reason %s in round %d", u.ID, r.Reason, r.Round)
+				}
+				if r.Cascaded() {
+					// The chain starts with a direct refusal.
+					first := solution.Refusals[r.Chain[0]]
+					if first[len(first)-1].Cascaded() {
+						t.Errorf("Chain %v of user %s does not start with a direct refusal", r.Chain, u.ID)
+					}
+				}
+			}
+		}
+
+		if got := solution.Refusals["Banned"]; !reflect.DeepEqual(got, []runner.Refusal{{Partition: "Test", Reason: runner.ReasonExcluded}}) {
+			t.Errorf("Excluded user has refusals %v", got)
+		}
+		if got := solution.Refusals["Friend"]; !reflect.DeepEqual(got, []runner.Refusal{{Partition: "Test", Reason: runner.ReasonDependency, Chain: []runner.UserID{"Banned"}}}) {
+			t.Errorf("Dependee of excluded user has refusals %v", got)
+		}
+		if r := solution.Refusals["B"]; len(r) > 0 && r[0].Cascaded() && !slices.Equal(r[0].Chain, []runner.UserID{"A"}) {
+			t.Errorf("B was refused through chain %v, want [A]", r[0].Chain)
+		}
+		if r := solution.Refusals["D"]; len(r) > 0 && r[0].Reason == runner.ReasonGroup && r[0].Chain[len(r[0].Chain)-1] != "C" {
+			t.Errorf("D was refused for its group through chain %v, want it to end with C", r[0].Chain)
+		}
+		if r := solution.Refusals["E"]; r[0].Partition != "Full" || r[0].Stage != 0 || len(r) > 1 && (r[1].Partition != "Test" || r[1].Stage != 1) {
+			t.Errorf("Fallback user has refusals %v", r)
+		}
+	}
+}
+
+func TestRun_RefusalsAcrossStages(t *testing.T) {
+	users := []runner.User{
+		mkUser("Right", "A"),
+		{Partition: "Left", ID: "X", Deps: []runner.UserID{"A"}, Fallbacks: []runner.Partition{"Right"}},
+		{Partition: "Left", ID: "B", State: runner.Guaranteed},
+		// Y can never get a pass, as its dependency group needs more users than it has.
+		{Partition: "Left", ID: "Y", DepGroups: []runner.DepGroup{{Users: []runner.UserID{"B"}, Min: 2}}, Fallbacks: []runner.Partition{"Right"}},
+	}
+	problem := runner.Compile(users)
+	availability := []runner.Availability{
+		{Partition: "Left", Available: 1},
+		{Partition: "Right", Available: 0},
+	}
+
+	for seed := range int64(20) {
+		solution, err := problem.Run(rand.New(rand.NewSource(seed)), availability)
+		if err != nil {
+			t.Fatalf("Run failed unexpectedly: %s", err)
+		}
+		for _, tc := range []struct {
+			user runner.UserID
+			want runner.Refusal
+		}{
+			// A was refused for good in stage 0, so X is refused for its fallback as soon as it becomes a candidate.
+			{user: "X", want: runner.Refusal{Partition: "Right", Stage: 1, Reason: runner.ReasonDependency, Chain: []runner.UserID{"A"}}},
+			{user: "Y", want: runner.Refusal{Partition: "Right", Stage: 1, Reason: runner.ReasonDependency}},
+		} {
+			refusals := solution.Refusals[tc.user]
+			if len(refusals) != 2 || !reflect.DeepEqual(refusals[1], tc.want) {
+				t.Errorf("User %s has refusals %v, want the last to be %v", tc.user, refusals, tc.want)
+			}
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	users := mkFreeUsers("Left", "L", 8)
+	users = append(users, mkFreeUsers("Right", "R", 8)...)
+	users = append(users, mkUserCouple("Left", "Right", "Couple")...)
+	users = append(users,
+		runner.User{Partition: "Left", ID: "Banned", State: runner.Excluded},
+		mkUser("Right", "Friend", "Banned"),
+		runner.User{Partition: "Full", ID: "Fallback", Fallbacks: []runner.Partition{"Left"}},
+	)
+	problem := runner.Compile(users)
+	availability := []runner.Availability{
+		{Partition: "Left", Available: 4},
+		{Partition: "Right", Available: 5},
+		{Partition: "Full", Available: 0},
+	}
+
+	for _, strategy := range runner.Strategies {
+		for seed := range int64(20) {
+			want, err := problem.RunStrategy(strategy, rand.New(rand.NewSource(seed)), availability)
+			if err != nil {
+				t.Fatalf("Run failed unexpectedly: %s", err)
+			}
+			events, err := problem.Events(strategy, rand.New(rand.NewSource(seed)), availability)
+			if err != nil {
+				t.Fatalf("Events failed unexpectedly: %s", err)
+			}
+
+			var got *runner.Solution
+			refusals := make(map[runner.UserID][]runner.Refusal)
+			stage, round := -1, 0
+			for e := range events {
+				if got != nil {
+					t.Fatalf("%s: event %v after the draw finished", strategy.Name(), e)
+				}
+				switch e.Kind {
+				case runner.EventStageStarted:
+					if e.Stage != stage+1 {
+						t.Errorf("%s: stage %d started after stage %d", strategy.Name(), e.Stage, stage)
+					}
+					stage, round = e.Stage, 0
+				case runner.EventRoundStarted:
+					if e.Stage != stage || e.Round <= round {
+						t.Errorf("%s: round %d of stage %d started after round %d of stage %d", strategy.Name(), e.Round, e.Stage, round, stage)
+					}
+					round = e.Round
+				case runner.EventUserExcluded, runner.EventUserDrawn, runner.EventCascadeRefusal:
+					if e.Stage != stage || e.Round != round {
+						t.Errorf("%s: %s of %s in round %d of stage %d, but round %d of stage %d was announced", strategy.Name(), e.Kind, e.User, e.Round, e.Stage, round, stage)
+					}
+					if (e.Kind == runner.EventCascadeRefusal) != (len(e.Chain) > 0) {
+						t.Errorf("%s: %s of %s has chain %v", strategy.Name(), e.Kind, e.User, e.Chain)
+					}
+					refusals[e.User] = append(refusals[e.User], runner.Refusal{Partition: e.Partition, Stage: e.Stage, Round: e.Round, Chain: e.Chain})
+				case runner.EventFinished:
+					got = e.Solution
+				}
+			}
+			if got == nil {
+				t.Fatalf("%s: events did not finish", strategy.Name())
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: events with seed %d finished with a different solution than Run", strategy.Name(), seed)
+			}
+			for u, rs := range want.Refusals {
+				for i := range rs {
+					rs[i].Reason = ""
+				}
+				if !reflect.DeepEqual(refusals[u], rs) {
+					t.Errorf("%s: events refused %s with %v, but Run traced %v", strategy.Name(), u, refusals[u], rs)
+				}
+			}
+		}
+	}
+
+	events, err := problem.Events(runner.Backward, rand.New(rand.NewSource(1)), availability)
+	if err != nil {
+		t.Fatalf("Events failed unexpectedly: %s", err)
+	}
+	for range events {
+		break
+	}
+
+	guaranteed := append(slices.Clone(users), runner.User{Partition: "Full", ID: "Teacher", State: runner.Guaranteed})
+	if _, err := runner.Compile(guaranteed).Events(runner.Backward, rand.New(rand.NewSource(1)), availability); err == nil {
+		t.Errorf("Events succeeded with more guaranteed users than passes")
+	}
+}
+
+func TestProbabilities(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		numUsers  int
+		numPasses int
+		wantProb  float64
+	}{
+		{name: "10 users, 3 passes", numUsers: 10, numPasses: 3, wantProb: 3.0 / 10.0},
+		{name: "12 users, 12 passes", numUsers: 12, numPasses: 12, wantProb: 1.0},
+		{name: "12 users, 11 passes", numUsers: 12, numPasses: 11, wantProb: 11.0 / 12.0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := runner.Compile(mkFreeUsers("Test", "Free", tc.numUsers))
+			probs, err := p.Probabilities([]runner.Availability{{Partition: "Test", Available: tc.numPasses}})
+			if err != nil {
+				t.Fatalf("Probabilities failed unexpectedly: %s", err)
+			}
+			for u, prob := range sortedKeys(probs) {
+				if diff := math.Abs(prob - tc.wantProb); diff > 1e-9 {
+					t.Errorf("Probabilities produced unexpected probability: user=%s got %f, want %f", u, prob, tc.wantProb)
+				}
+			}
+		})
+	}
+
+	t.Run("weights", func(t *testing.T) {
+		users := mkFreeUsers("Test", "Free", 8)
+		users = append(users, runner.User{Partition: "Test", ID: "Weighted", Weight: 2.0})
+		probs, err := runner.Compile(users).Probabilities([]runner.Availability{{Partition: "Test", Available: 3}})
+		if err != nil {
+			t.Fatalf("Probabilities failed unexpectedly: %s", err)
+		}
+		// All passes are handed out.
+		var sum float64
+		for _, prob := range probs {
+			sum += prob
+		}
+		if math.Abs(sum-3) > 1e-9 {
+			t.Errorf("Probabilities do not add up to the passes: got %f, want 3", sum)
+		}
+		if probs["Weighted"] <= 2*probs["Free0"]*0.9 {
+			t.Errorf("Weighted user has too low probability: got %f, free users got %f", probs["Weighted"], probs["Free0"])
+		}
+	})
+
+}
+
+// The exact probabilities serve as oracle for Run.
+func TestProbabilities_MatchRun(t *testing.T) {
+	users := mkFreeUsers("Left", "FreeL", 4)
+	users = append(users, mkFreeUsers("Right", "FreeR", 3)...)
+	users = append(users, mkUserCouple("Left", "Right", "Couple")...)
+	users = append(users,
+		runner.User{Partition: "Left", ID: "AnyOf", DepGroups: []runner.DepGroup{{Users: []runner.UserID{"FreeR0", "FreeR1"}}}},
+		runner.User{Partition: "Left", ID: "Crew0", Group: "crew"},
+		runner.User{Partition: "Right", ID: "Crew1", Group: "crew"},
+		runner.User{Partition: "Right", ID: "Fallback", Fallbacks: []runner.Partition{"Left"}},
+		runner.User{Partition: "Right", ID: "Heavy", Weight: 3},
+		runner.User{Partition: "Left", ID: "Teacher", State: runner.Guaranteed},
+		runner.User{Partition: "Left", ID: "Banned", State: runner.Excluded},
+	)
+	availability := []runner.Availability{
+		{Partition: "Left", Available: 5},
+		{Partition: "Right", Available: 4},
+	}
+
+	probs, err := runner.Compile(users).Probabilities(availability)
+	if err != nil {
+		t.Fatalf("Probabilities failed unexpectedly: %s", err)
+	}
+
+	r := runner.NewWithRand(users, rand.New(rand.NewSource(5544332211)))
+	stats := runStats(t, r, availability, 20000)
+	allowDelta := 0.015
+	for u, want := range sortedKeys(probs) {
+		var got float64
+		for _, partStats := range stats {
+			got += partStats[u]
+		}
+		if diff := math.Abs(got - want); diff > allowDelta {
+			t.Errorf("Run produced unexpected probabilities: user=%s got %f, want %f, diff: %f", u, got, want, diff)
+		}
+	}
+}
+
+func TestStrategies(t *testing.T) {
+	users := mkFreeUsers("Left", "FreeL", 20)
+	users = append(users, mkFreeUsers("Right", "FreeR", 10)...)
+	for i := range 5 {
+		users = append(users, mkUserCouple("Left", "Right", fmt.Sprintf("Couple%d", i))...)
+	}
+	users = append(users,
+		runner.User{Partition: "Left", ID: "Crew0", Group: "crew"},
+		runner.User{Partition: "Right", ID: "Crew1", Group: "crew"},
+		runner.User{Partition: "Left", ID: "Fallback", Fallbacks: []runner.Partition{"Right"}},
+		runner.User{Partition: "Left", ID: "Teacher", State: runner.Guaranteed},
+		runner.User{Partition: "Left", ID: "Banned", State: runner.Excluded},
+	)
+	userByID := make(map[runner.UserID]runner.User)
+	for _, u := range users {
+		userByID[u.ID] = u
+	}
+	availability := []runner.Availability{
+		{Partition: "Left", Available: 12},
+		{Partition: "Right", Available: 14},
+	}
+
+	for _, strategy := range runner.Strategies {
+		t.Run(strategy.Name(), func(t *testing.T) {
+			if got, err := runner.StrategyByName(strategy.Name()); err != nil || got != strategy {
+				t.Fatalf("StrategyByName(%q) = %v, %v", strategy.Name(), got, err)
+			}
+
+			r := runner.NewWithRand(users, rand.New(rand.NewSource(5544332211)))
+			r.SetStrategy(strategy)
+			runCount := 5000
+			passes := make(map[runner.UserID]int)
+			for i := 0; i < runCount; i++ {
+				solution, err := r.Run(availability)
+				if err != nil {
+					t.Fatalf("Run failed unexpectedly: %s", err)
+				}
+
+				hasPass := make(map[runner.UserID]bool)
+				for part, partPass := range solution.Passes {
+					if n := len(partPass); part == "Left" && n > 12 || n > 14 {
+						t.Fatalf("Handed out %d passes for partition %s", n, part)
+					}
+					for _, u := range partPass {
+						if hasPass[u] {
+							t.Fatalf("User %s got more than one pass", u)
+						}
+						hasPass[u] = true
+						passes[u]++
+					}
+				}
+				for u := range hasPass {
+					for _, dep := range userByID[u].Deps {
+						if !hasPass[dep] {
+							t.Fatalf("User %s got a pass without its dependency %s", u, dep)
+						}
+					}
+				}
+				if hasPass["Crew0"] != hasPass["Crew1"] {
+					t.Fatalf("Group was only partially admitted")
+				}
+				if !hasPass["Teacher"] || hasPass["Banned"] {
+					t.Fatalf("Guaranteed or excluded user was drawn")
+				}
+				for _, waitlist := range solution.Waitlist {
+					for _, u := range waitlist {
+						if hasPass[u] {
+							t.Fatalf("User %s is on the waitlist but has a pass", u)
+						}
+					}
+				}
+			}
+
+			// Free users of the same partition are treated equally.
+			prob := func(u runner.UserID) float64 {
+				return float64(passes[u]) / float64(runCount)
+			}
+			for i := 1; i < 20; i++ {
+				u := runner.UserID(fmt.Sprintf("FreeL%d", i))
+				if diff := math.Abs(prob(u) - prob("FreeL0")); diff > 0.05 {
+					t.Errorf("Free users have different probabilities: %s got %f, FreeL0 got %f", u, prob(u), prob("FreeL0"))
+				}
+			}
+		})
+	}
+
+	if _, err := runner.StrategyByName("unknown"); err == nil {
+		t.Errorf("StrategyByName succeeded for an unknown strategy")
+	}
+}
+
+func TestTickets(t *testing.T) {
+	users := mkFreeUsers("Left", "FreeL", 30)
+	users = append(users, mkFreeUsers("Right", "FreeR", 30)...)
+	users = append(users, mkUser("Left", "Late", "Typo"))
+	availability := []runner.Availability{
+		{Partition: "Left", Available: 15},
+		{Partition: "Right", Available: 15},
+	}
+
+	draw := func(users []runner.User) *runner.Solution {
+		r := runner.NewWithRand(users, rand.New(rand.NewSource(5544332211)))
+		r.SetStrategy(runner.Tickets)
+		solution, err := r.Run(availability)
+		if err != nil {
+			t.Fatalf("Run failed unexpectedly: %s", err)
+		}
+		return solution
+	}
+
+	before := draw(users)
+	if before.TicketSeed == 0 {
+		t.Errorf("Solution does not record the ticket seed")
+	}
+
+	// Correct the dependency; nothing changes for the other partition.
+	corrected := slices.Clone(users)
+	corrected[len(corrected)-1] = mkUser("Left", "Late", "FreeL0")
+	after := draw(corrected)
+	if after.TicketSeed != before.TicketSeed {
+		t.Errorf("Ticket seed changed after correction: got %d, want %d", after.TicketSeed, before.TicketSeed)
+	}
+	if !reflect.DeepEqual(after.Passes["Right"], before.Passes["Right"]) {
+		t.Errorf("Correction changed passes of unrelated partition: got %v, want %v", after.Passes["Right"], before.Passes["Right"])
+	}
+
+	// Tickets only depend on the seed and the ID.
+	got := runner.Compile(users).Tickets(42)["FreeL3"]
+	if want := runner.Compile(corrected[:10]).Tickets(42)["FreeL3"]; got != want || got <= 0 || got >= 1 {
+		t.Errorf("Tickets differ between problems: got %f, want %f", got, want)
+	}
+}
+
+// Tickets draws with the same probabilities as Backward.
+func TestTickets_MatchProbabilities(t *testing.T) {
+	users := mkFreeUsers("Left", "FreeL", 4)
+	users = append(users, mkFreeUsers("Right", "FreeR", 3)...)
+	users = append(users, mkUserCouple("Left", "Right", "Couple")...)
+	users = append(users,
+		runner.User{Partition: "Left", ID: "Friend", Deps: []runner.UserID{"FreeR0"}},
+		runner.User{Partition: "Right", ID: "Fallback", Fallbacks: []runner.Partition{"Left"}},
+		runner.User{Partition: "Right", ID: "Heavy", Weight: 3},
+	)
+	availability := []runner.Availability{
+		{Partition: "Left", Available: 4},
+		{Partition: "Right", Available: 3},
+	}
+
+	probs, err := runner.Compile(users).Probabilities(availability)
+	if err != nil {
+		t.Fatalf("Probabilities failed unexpectedly: %s", err)
+	}
+
+	r := runner.NewWithRand(users, rand.New(rand.NewSource(5544332211)))
+	r.SetStrategy(runner.Tickets)
+	stats := runStats(t, r, availability, 20000)
+	allowDelta := 0.015
+	for u, want := range sortedKeys(probs) {
+		var got float64
+		for _, partStats := range stats {
+			got += partStats[u]
+		}
+		if diff := math.Abs(got - want); diff > allowDelta {
+			t.Errorf("Run produced unexpected probabilities: user=%s got %f, want %f, diff: %f", u, got, want, diff)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	users := []runner.User{
+		mkUser("Left", "Free"),
+		mkUser("Left", "A", "B"),
+		mkUser("Left", "B", "C"),
+		mkUser("Right", "C"),
+		mkUser("Left", "X", "Y"),
+		mkUser("Right", "Y", "X"),
+		mkUser("Left", "Z", "X", "C"),
+		mkUser("Left", "P", "Q"),
+		mkUser("Right", "Q", "R"),
+		mkUser("Right", "R", "P"),
+		{Partition: "Right", ID: "AnyOf", DepGroups: []runner.DepGroup{{Users: []runner.UserID{"A", "C"}}}},
+	}
+	g := runner.Compile(users).Analyze()
+
+	wantComponents := [][]runner.UserID{{"P", "Q", "R"}, {"X", "Y"}}
+	if !reflect.DeepEqual(g.Components, wantComponents) {
+		t.Errorf("Analyze found unexpected components: got %v, want %v", g.Components, wantComponents)
+	}
+
+	wantDepth := map[runner.UserID]int{
+		"Free": 0, "C": 0, "B": 1, "A": 2, "AnyOf": 3,
+		"X": 0, "Y": 0, "Z": 1, "P": 0, "Q": 0, "R": 0,
+	}
+	if !reflect.DeepEqual(g.Depth, wantDepth) {
+		t.Errorf("Analyze computed unexpected depths: got %v, want %v", g.Depth, wantDepth)
+	}
+
+	wantFanIn := map[runner.UserID]int{
+		"Free": 0, "A": 1, "B": 1, "C": 3, "AnyOf": 0,
+		"X": 2, "Y": 1, "Z": 0, "P": 1, "Q": 1, "R": 1,
+	}
+	if !reflect.DeepEqual(g.FanIn, wantFanIn) {
+		t.Errorf("Analyze computed unexpected fan-in: got %v, want %v", g.FanIn, wantFanIn)
+	}
+}
+
+func TestBalance(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		ratio     runner.Ratio
+		caps      [2]int
+		demand    [2]int
+		wantLeft  int
+		wantRight int
+	}{
+		{
+			name:      "both over-subscribed",
+			ratio:     runner.Ratio{Total: 100, Min: 0.9, Max: 1.1},
+			caps:      [2]int{100, 100},
+			demand:    [2]int{200, 200},
+			wantLeft:  50,
+			wantRight: 50,
+		},
+		{
+			name:      "left under-subscribed",
+			ratio:     runner.Ratio{Total: 100, Min: 0.9, Max: 1.1},
+			caps:      [2]int{100, 100},
+			demand:    [2]int{40, 200},
+			wantLeft:  40,
+			wantRight: 44,
+		},
+		{
+			name:      "right under-subscribed",
+			ratio:     runner.Ratio{Total: 100, Min: 0.5, Max: 2},
+			caps:      [2]int{100, 100},
+			demand:    [2]int{200, 30},
+			wantLeft:  60,
+			wantRight: 30,
+		},
+		{
+			name:      "capped",
+			ratio:     runner.Ratio{Total: 100, Min: 0.5, Max: 2},
+			caps:      [2]int{45, 100},
+			demand:    [2]int{200, 200},
+			wantLeft:  45,
+			wantRight: 55,
+		},
+		{
+			name:      "follows demand within bounds",
+			ratio:     runner.Ratio{Total: 100, Min: 0.5, Max: 2},
+			caps:      [2]int{100, 100},
+			demand:    [2]int{300, 200},
+			wantLeft:  60,
+			wantRight: 40,
+		},
+		{
+			name:      "no demand",
+			ratio:     runner.Ratio{Total: 100, Min: 0.9, Max: 1.1},
+			caps:      [2]int{100, 100},
+			demand:    [2]int{0, 200},
+			wantLeft:  0,
+			wantRight: 100,
+		},
+		{
+			name:      "no demand capped",
+			ratio:     runner.Ratio{Total: 100, Min: 0.9, Max: 1.1},
+			caps:      [2]int{100, 60},
+			demand:    [2]int{0, 200},
+			wantLeft:  0,
+			wantRight: 60,
+		},
+		{
+			name:      "no split within bounds",
+			ratio:     runner.Ratio{Total: 10, Min: 2, Max: 3},
+			caps:      [2]int{10, 10},
+			demand:    [2]int{1, 1},
+			wantLeft:  1,
+			wantRight: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.ratio.Left = "Left"
+			tc.ratio.Right = "Right"
+			got := runner.Balance(
+				[]runner.Availability{
+					{Partition: "Left", Available: tc.caps[0]},
+					{Partition: "Right", Available: tc.caps[1]},
+					{Partition: "Other", Available: 7},
+				},
+				[]runner.Ratio{tc.ratio},
+				map[runner.Partition]int{"Left": tc.demand[0], "Right": tc.demand[1], "Other": 10},
+			)
+			want := []runner.Availability{
+				{Partition: "Left", Available: tc.wantLeft},
+				{Partition: "Right", Available: tc.wantRight},
+				{Partition: "Other", Available: 7},
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Balance returned unexpected availabilities: got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRecycle(t *testing.T) {
+	users := []runner.User{
+		mkUser("Left", "L1"),
+		mkUser("Left", "L2"),
+		mkUser("Left", "L3", "R3"),
+		mkUser("Left", "L4"),
+		mkUser("Right", "R1", "L1"),
+		mkUser("Right", "R2"),
+		mkUser("Right", "R3"),
+		mkUser("Right", "R4"),
+	}
+	availability := []runner.Availability{
+		{Partition: "Left", Available: 2},
+		{Partition: "Right", Available: 2},
+	}
+	prev := &runner.Solution{
+		Passes: map[runner.Partition][]runner.UserID{
+			"Left":  {"L1", "L2"},
+			"Right": {"R1", "R2"},
+		},
+		Waitlist: map[runner.Partition][]runner.UserID{
+			"Left":  {"L3", "L4"},
+			"Right": {"R4", "R3"},
+		},
+	}
+
+	for _, tc := range []struct {
+		name        string
+		canceled    []runner.UserID
+		policy      runner.CancelPolicy
+		wantPasses  map[runner.Partition][]runner.UserID
+		wantChanges runner.ChangeSet
+	}{
+		{
+			name:     "dependee loses pass",
+			canceled: []runner.UserID{"L1"},
+			policy:   runner.RefuseDependees,
+			// L3 needs R3, and there is room for both.
+			wantPasses: map[runner.Partition][]runner.UserID{
+				"Left":  {"L2", "L3"},
+				"Right": {"R2", "R3"},
+			},
+			wantChanges: runner.ChangeSet{
+				Gained: map[runner.Partition][]runner.UserID{"Left": {"L3"}, "Right": {"R3"}},
+				Lost:   map[runner.Partition][]runner.UserID{"Left": {"L1"}, "Right": {"R1"}},
+			},
+		},
+		{
+			name:     "dependee keeps pass",
+			canceled: []runner.UserID{"L1"},
+			policy:   runner.KeepDependees,
+			// L3 needs R3, but there is no room in Right.
+			wantPasses: map[runner.Partition][]runner.UserID{
+				"Left":  {"L2", "L4"},
+				"Right": {"R1", "R2"},
+			},
+			wantChanges: runner.ChangeSet{
+				Gained: map[runner.Partition][]runner.UserID{"Left": {"L4"}},
+				Lost:   map[runner.Partition][]runner.UserID{"Left": {"L1"}},
+			},
+		},
+		{
+			name:     "waitlist order",
+			canceled: []runner.UserID{"R2"},
+			policy:   runner.RefuseDependees,
+			wantPasses: map[runner.Partition][]runner.UserID{
+				"Left":  {"L1", "L2"},
+				"Right": {"R1", "R4"},
+			},
+			wantChanges: runner.ChangeSet{
+				Gained: map[runner.Partition][]runner.UserID{"Right": {"R4"}},
+				Lost:   map[runner.Partition][]runner.UserID{"Right": {"R2"}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := runner.New(users)
+			got, changes, err := r.Recycle(availability, prev, tc.canceled, tc.policy)
+			if err != nil {
+				t.Fatalf("Recycle failed unexpectedly: %s", err)
+			}
+			if !reflect.DeepEqual(got.Passes, tc.wantPasses) {
+				t.Errorf("Recycle produced unexpected passes: got %v, want %v", got.Passes, tc.wantPasses)
+			}
+			if !reflect.DeepEqual(*changes, tc.wantChanges) {
+				t.Errorf("Recycle produced unexpected changes: got %v, want %v", *changes, tc.wantChanges)
+			}
+		})
+	}
+
+	// Users of a dependency group keep their pass while enough of the group hold one.
+	groupUsers := []runner.User{
+		mkUser("Test", "A"),
+		mkUser("Test", "B"),
+		{Partition: "Test", ID: "C", DepGroups: []runner.DepGroup{{Users: []runner.UserID{"A", "B"}}}},
+	}
+	groupPrev := &runner.Solution{
+		Passes:   map[runner.Partition][]runner.UserID{"Test": {"A", "B", "C"}},
+		Waitlist: map[runner.Partition][]runner.UserID{"Test": {}},
+	}
+	groupAvailability := []runner.Availability{{Partition: "Test", Available: 3}}
+	for _, tc := range []struct {
+		name       string
+		canceled   []runner.UserID
+		wantPasses []runner.UserID
+	}{
+		{name: "dependency group still satisfied", canceled: []runner.UserID{"A"}, wantPasses: []runner.UserID{"B", "C"}},
+		{name: "dependency group no longer satisfied", canceled: []runner.UserID{"A", "B"}, wantPasses: []runner.UserID{}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := runner.New(groupUsers)
+			got, _, err := r.Recycle(groupAvailability, groupPrev, tc.canceled, runner.RefuseDependees)
+			if err != nil {
+				t.Fatalf("Recycle failed unexpectedly: %s", err)
+			}
+			if !slices.Equal(got.Passes["Test"], tc.wantPasses) {
+				t.Errorf("Recycle produced unexpected passes: got %v, want %v", got.Passes["Test"], tc.wantPasses)
+			}
+		})
+	}
+
+	// Canceling a member of a group takes the pass of the whole group only when refusing dependees.
+	crewUsers := []runner.User{
+		{Partition: "Test", ID: "A", Group: "crew"},
+		{Partition: "Test", ID: "B", Group: "crew"},
+		mkUser("Test", "C"),
+	}
+	crewPrev := &runner.Solution{
+		Passes:   map[runner.Partition][]runner.UserID{"Test": {"A", "B"}},
+		Waitlist: map[runner.Partition][]runner.UserID{"Test": {"C"}},
+	}
+	crewAvailability := []runner.Availability{{Partition: "Test", Available: 2}}
+	for _, tc := range []struct {
+		name       string
+		policy     runner.CancelPolicy
+		wantPasses []runner.UserID
+	}{
+		{name: "group member canceled, refuse", policy: runner.RefuseDependees, wantPasses: []runner.UserID{"C"}},
+		{name: "group member canceled, keep", policy: runner.KeepDependees, wantPasses: []runner.UserID{"B", "C"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := runner.New(crewUsers)
+			got, _, err := r.Recycle(crewAvailability, crewPrev, []runner.UserID{"A"}, tc.policy)
+			if err != nil {
+				t.Fatalf("Recycle failed unexpectedly: %s", err)
+			}
+			if !slices.Equal(got.Passes["Test"], tc.wantPasses) {
+				t.Errorf("Recycle produced unexpected passes: got %v, want %v", got.Passes["Test"], tc.wantPasses)
+			}
+		})
+	}
+}
+
+func TestFillUp(t *testing.T) {
+	users := mkFreeUsers("Left", "FreeL", 5)
+	users = append(users, mkFreeUsers("Right", "R", 10)...)
+	for i := range 5 {
+		users = append(users, mkUser("Left", fmt.Sprintf("L->R%d", i), runner.UserID(fmt.Sprintf("R%d", i))))
+	}
+	availability := []runner.Availability{
+		{Partition: "Left", Available: 6},
+		{Partition: "Right", Available: 2},
+	}
+	r := runner.NewWithRand(users, rand.New(rand.NewSource(5544332211)))
+
+	recovered := 0
+	for range 1000 {
+		solution, err := r.Run(availability)
+		if err != nil {
+			t.Fatalf("Run failed unexpectedly: %s", err)
+		}
+		filled, changes, err := r.FillUp(availability, solution)
+		if err != nil {
+			t.Fatalf("FillUp failed unexpectedly: %s", err)
+		}
+		if len(changes.Lost) > 0 {
+			t.Fatalf("FillUp took passes away: %v", changes.Lost)
+		}
+		for _, u := range solution.Passes["Left"] {
+			if !slices.Contains(filled.Passes["Left"], u) {
+				t.Fatalf("FillUp took pass of %s away", u)
+			}
+		}
+		recovered += len(changes.Gained["Left"])
+
+		// All free users and users whose dependency got a pass are eligible.
+		eligible := 5
+		for _, u := range filled.Passes["Right"] {
+			if u < "R5" {
+				eligible++
+			}
+		}
+		if got, want := len(filled.Passes["Left"]), min(6, eligible); got != want {
+			t.Fatalf("FillUp did not fill partition: got %d passes, want %d", got, want)
+		}
+	}
+
+	if recovered == 0 {
+		t.Errorf("FillUp never recovered a pass")
+	}
+}
+
+func BenchmarkRun(b *testing.B) {
+	for _, numUsers := range []int{10_000, 100_000, 1_000_000} {
+		b.Run(fmt.Sprintf("%d users", numUsers), func(b *testing.B) {
+			// Half of the users are in couples across partitions.
+			var users []runner.User
+			users = append(users, mkFreeUsers("Left", "FreeL", numUsers/4)...)
+			users = append(users, mkFreeUsers("Right", "FreeR", numUsers/4)...)
+			for i := 0; i < numUsers/4; i++ {
+				users = append(users, mkUserCouple("Left", "Right", fmt.Sprintf("Couple%d", i))...)
+			}
+			r := runner.NewWithRand(users, rand.New(rand.NewSource(5544332211)))
+			availability := []runner.Availability{
+				{Partition: "Left", Available: numUsers / 4},
+				{Partition: "Right", Available: numUsers / 3},
+			}
+
+			b.ResetTimer()
+			for range b.N {
+				if _, err := r.Run(availability); err != nil {
+					b.Fatalf("Run failed unexpectedly: %s", err)
+				}
+			}
+		})
+	}
+}
+
 func runStats(t *testing.T, r *runner.Runner, availabilities []runner.Availability, runCount int) map[runner.Partition]map[runner.UserID]float64 {
 	passes := make(map[runner.Partition]map[runner.UserID]int)
 	for i := 0; i < runCount; i++ {
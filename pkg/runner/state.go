@@ -0,0 +1,346 @@
+package runner
+
+import (
+	"math/rand"
+	"slices"
+)
+
+// state is the disposable state of a single run of a problem.
+type state struct {
+	p    *Problem
+	rand *rand.Rand
+
+	// assigned holds the current partition of every user, or -1 if refused.
+	assigned []int
+	// choice is the index into the preferences of every user.
+	choice []int
+	// candidate is true for users that can still be refused in the current stage.
+	candidate     []bool
+	numCandidates []int
+	// granted is true for candidates that grant strategies picked to get a pass in the current stage.
+	// They can still be refused if their dependencies are refused.
+	granted    []bool
+	numGranted []int
+	// holders counts users that got a pass in a previous stage, or are guaranteed.
+	holders []int
+	// pools allow drawing weighted refusals from the candidates of the current stage.
+	pools []*pool
+	// poolPos is the position of every candidate in its pool.
+	poolPos []int
+	// ticketSeed and ticketKeys are set by the Tickets strategy; ticketKeys holds
+	// the weighted key of every user.
+	ticketSeed int64
+	ticketKeys []float64
+	// refusals holds the refused users of every partition in order of refusal.
+	// It is nil if the order is not needed.
+	refusals [][]int
+	// refusalTrace holds all refusals in order, see Solution.Refusals.
+	// It is nil unless enabled by startTrace.
+	refusalTrace []tracedRefusal
+	// lastRefusal is the index of the latest refusal of every user in refusalTrace.
+	lastRefusal []int
+	// stage and round count the stages of the draw, and the rounds of the current stage.
+	stage int
+	round int
+	// roundPending is true if the current round was not announced yet.
+	roundPending bool
+	// closed is true for partitions that are done drawing users in the current stage.
+	closed []bool
+	// emit, if not nil, receives every step of the draw; see Problem.Events.
+	emit func(Event)
+}
+
+// newState creates the state before the draw. The rand may only be nil if
+// the state is never used to draw refusals.
+func newState(p *Problem, rand *rand.Rand) *state {
+	n := len(p.users)
+	s := &state{
+		p:             p,
+		rand:          rand,
+		assigned:      make([]int, n),
+		choice:        make([]int, n),
+		candidate:     make([]bool, n),
+		numCandidates: make([]int, len(p.partitions)),
+		granted:       make([]bool, n),
+		numGranted:    make([]int, len(p.partitions)),
+		holders:       make([]int, len(p.partitions)),
+		closed:        make([]bool, len(p.partitions)),
+		poolPos:       make([]int, n),
+		refusals:      make([][]int, len(p.partitions)),
+	}
+	for u := range p.users {
+		part := p.prefs[u][0]
+		if p.users[u].State == Guaranteed {
+			s.assigned[u] = part
+			s.holders[part]++
+		} else {
+			s.addCandidate(u, part)
+		}
+	}
+	return s
+}
+
+// addCandidate makes the user a candidate for a pass of the given partition.
+func (s *state) addCandidate(u int, part int) {
+	s.assigned[u] = part
+	s.candidate[u] = true
+	s.numCandidates[part]++
+}
+
+func (s *state) isRefused(u int) bool {
+	return s.assigned[u] < 0
+}
+
+// Mark user as refused without propagating the refusal.
+func (s *state) shallowRefuse(u int) {
+	part := s.assigned[u]
+	if s.candidate[u] {
+		s.candidate[u] = false
+		s.numCandidates[part]--
+		if s.granted[u] {
+			s.granted[u] = false
+			s.numGranted[part]--
+		}
+		if s.pools != nil {
+			s.pools[part].tree.remove(s.poolPos[u])
+		}
+	}
+	s.assigned[u] = -1
+	if s.refusals != nil {
+		s.refusals[part] = append(s.refusals[part], u)
+	}
+}
+
+// refuse refuses the drawn user, all other users of its group,
+// and all users whose dependencies can no longer be satisfied because of it.
+// Returns true if any user was newly refused.
+func (s *state) refuse(u int) bool {
+	return s.refuseBecause(u, -1, ReasonDrawn)
+}
+
+// refuseBecause refuses the user like refuse, because of the refusal of user cause, or -1.
+func (s *state) refuseBecause(u int, cause int, reason Reason) bool {
+	if s.isRefused(u) {
+		// Already refused
+		return false
+	}
+	if s.p.users[u].State == Guaranteed {
+		return false
+	}
+
+	s.trace(u, cause, reason)
+	s.notifyRefusal(u, reason)
+	s.shallowRefuse(u)
+	if g := s.p.groupOf[u]; g >= 0 {
+		for _, m := range s.p.groups[g] {
+			s.refuseBecause(m, u, ReasonGroup)
+		}
+	}
+	for _, d := range s.p.dependees[u] {
+		if !s.satisfiable(d) {
+			s.refuseBecause(d, u, ReasonDependency)
+		}
+	}
+	return true
+}
+
+// satisfiable returns true if the dependencies and the group of the user can
+// still be satisfied by the users that are not refused yet.
+func (s *state) satisfiable(u int) bool {
+	_, reason := s.blocker(u)
+	return reason == ""
+}
+
+// blocker returns a refused user that keeps the user from getting a pass, and why.
+// The user is -1 if no single user is to blame, and the reason is empty if
+// the dependencies and the group of the user can still be satisfied.
+func (s *state) blocker(u int) (int, Reason) {
+	for _, dep := range s.p.deps[u] {
+		if s.isRefused(dep) {
+			return dep, ReasonDependency
+		}
+	}
+	for _, g := range s.p.depGroups[u] {
+		possible := 0
+		refused := -1
+		for _, dep := range g.users {
+			if !s.isRefused(dep) {
+				possible++
+			} else if refused < 0 {
+				refused = dep
+			}
+		}
+		if possible < g.required {
+			return refused, ReasonDependency
+		}
+	}
+	if g := s.p.groupOf[u]; g >= 0 {
+		for _, m := range s.p.groups[g] {
+			if s.isRefused(m) {
+				return m, ReasonGroup
+			}
+		}
+	}
+	return -1, ""
+}
+
+func (s *state) run(strategy Strategy, availabilities []Availability) error {
+	available := s.p.available(availabilities)
+	s.notify(Event{Kind: EventStageStarted})
+	if err := s.start(available); err != nil {
+		return err
+	}
+
+	for {
+		s.openPartitions()
+		strategy.drawStage(s, available)
+		for part := range s.closed {
+			s.closePartition(part)
+		}
+		if !s.nextStage() {
+			break
+		}
+	}
+	return nil
+}
+
+// start checks the guaranteed users fit into the available passes,
+// and refuses the excluded users.
+func (s *state) start(available []int) error {
+	if err := s.p.checkGuaranteed(available); err != nil {
+		return err
+	}
+	for u, user := range s.p.users {
+		if user.State == Excluded {
+			s.refuseBecause(u, -1, ReasonExcluded)
+		}
+	}
+	return nil
+}
+
+// nextStage hands out passes to all remaining candidates, and makes refused users
+// candidates for their next fallback partition.
+// Returns false if there are no new candidates.
+func (s *state) nextStage() bool {
+	for u, isCandidate := range s.candidate {
+		if isCandidate {
+			s.holders[s.assigned[u]]++
+			s.candidate[u] = false
+			s.granted[u] = false
+		}
+	}
+	clear(s.numCandidates)
+	clear(s.numGranted)
+	s.pools = nil
+
+	var revived []int
+	for u, user := range s.p.users {
+		if !s.isRefused(u) || user.State == Excluded {
+			continue
+		}
+		prefs := s.p.prefs[u]
+		if s.choice[u]+1 >= len(prefs) {
+			continue
+		}
+		s.choice[u]++
+		s.addCandidate(u, prefs[s.choice[u]])
+		revived = append(revived, u)
+	}
+
+	if len(revived) == 0 {
+		return false
+	}
+	s.stage++
+	s.round = 0
+	s.roundPending = false
+	s.notify(Event{Kind: EventStageStarted})
+
+	// Candidates whose dependencies were refused for good cannot get a pass.
+	for _, u := range revived {
+		if cause, reason := s.blocker(u); reason != "" {
+			s.refuseBecause(u, cause, reason)
+		}
+	}
+	return true
+}
+
+func (s *state) solution() *Solution {
+	passes := make(map[Partition][]UserID)
+	waitlist := make(map[Partition][]UserID)
+	for _, part := range s.p.partitions {
+		passes[part] = []UserID{}
+		waitlist[part] = []UserID{}
+	}
+
+	for u, part := range s.assigned {
+		if part >= 0 {
+			partName := s.p.partitions[part]
+			passes[partName] = append(passes[partName], s.p.users[u].ID)
+		}
+	}
+
+	for part, refusals := range s.refusals {
+		partName := s.p.partitions[part]
+		// Users that got a pass in a fallback partition are not waiting anymore.
+		for i := len(refusals) - 1; i >= 0; i-- {
+			u := refusals[i]
+			if s.isRefused(u) && s.p.users[u].State != Excluded {
+				waitlist[partName] = append(waitlist[partName], s.p.users[u].ID)
+			}
+		}
+	}
+
+	solution := &Solution{
+		Passes:   passes,
+		Waitlist: waitlist,
+	}
+	if s.ticketKeys != nil {
+		solution.TicketSeed = s.ticketSeed
+	}
+	solution.Refusals = s.tracedRefusals()
+	return solution
+}
+
+// clone returns a copy of the state to explore the draw from,
+// without pools and without keeping the order or the trace of refusals.
+func (s *state) clone() *state {
+	return &state{
+		p:             s.p,
+		rand:          s.rand,
+		assigned:      slices.Clone(s.assigned),
+		choice:        slices.Clone(s.choice),
+		candidate:     slices.Clone(s.candidate),
+		numCandidates: slices.Clone(s.numCandidates),
+		granted:       slices.Clone(s.granted),
+		numGranted:    slices.Clone(s.numGranted),
+		holders:       slices.Clone(s.holders),
+	}
+}
+
+// pool holds the candidates of a partition for weighted drawing.
+type pool struct {
+	users []int
+	tree  *fenwick
+}
+
+// newPool creates a pool of the given users, in order, drawn by the given weights of all users.
+func (s *state) newPool(users []int, userWeights []float64) *pool {
+	weights := make([]float64, len(users))
+	for i, u := range users {
+		weights[i] = userWeights[u]
+	}
+	return &pool{
+		users: users,
+		tree:  newFenwick(weights),
+	}
+}
+
+// find returns the first user where the sum of the weights of all users up to
+// and including it reaches x.
+func (p *pool) find(x float64) (int, bool) {
+	i := p.tree.find(x)
+	if i < 0 {
+		return 0, false
+	}
+	return p.users[i], true
+}
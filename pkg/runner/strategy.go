@@ -0,0 +1,238 @@
+package runner
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/wchresta/passdraw/pkg/log"
+)
+
+// Strategy decides which candidates of a stage of the draw get a pass.
+//
+// All strategies keep the constraints of the users, and hand out passes in
+// stages for fallback partitions; they only differ in how they pick users.
+type Strategy interface {
+	// Name identifies the strategy, e.g. on the command line.
+	Name() string
+
+	// drawStage refuses candidates until every partition has enough passes for its candidates.
+	drawStage(s *state, available []int)
+}
+
+var (
+	// Backward draws who does not get a pass, round robin over all partitions,
+	// as described in the README. This is the default strategy.
+	Backward Strategy = backward{}
+	// Forward draws who gets a pass, round robin over all partitions.
+	// Once a partition is full, its remaining users are refused in the order they would have been drawn.
+	Forward Strategy = forward{}
+	// TicketOrder gives every user a random ticket, and hands out passes in ticket order
+	// while there is room in the user's partition.
+	TicketOrder Strategy = ticketOrder{}
+	// Tickets is the backward algorithm, with every refusal decided by the tickets of the users.
+	// At the start of a run, a ticket seed is drawn, and every user gets a ticket derived
+	// from the ticket seed and their ID only; see Problem.Tickets.
+	// Correcting the input and drawing again with the same seed keeps all tickets,
+	// so only the outcomes the correction affects change.
+	// The probabilities to get a pass are the same as with Backward.
+	Tickets Strategy = tickets{}
+)
+
+// Strategies lists all strategies.
+var Strategies = []Strategy{Backward, Forward, TicketOrder, Tickets}
+
+// StrategyByName returns the strategy with the given name.
+func StrategyByName(name string) (Strategy, error) {
+	for _, strategy := range Strategies {
+		if strategy.Name() == name {
+			return strategy, nil
+		}
+	}
+	var names []string
+	for _, strategy := range Strategies {
+		names = append(names, strategy.Name())
+	}
+	return nil, fmt.Errorf("unknown strategy %q, must be one of %v", name, names)
+}
+
+// fillPools creates a pool for every partition, holding its candidates drawn by the given weights.
+func (s *state) fillPools(userWeights []float64) {
+	poolUsers := make([][]int, len(s.p.partitions))
+	for u, isCandidate := range s.candidate {
+		if isCandidate {
+			part := s.assigned[u]
+			s.poolPos[u] = len(poolUsers[part])
+			poolUsers[part] = append(poolUsers[part], u)
+		}
+	}
+	s.pools = make([]*pool, len(s.p.partitions))
+	for part, users := range poolUsers {
+		s.pools[part] = s.newPool(users, userWeights)
+	}
+}
+
+// draw takes a random user from the pool of the partition, by weight.
+func (s *state) draw(part int) (int, bool) {
+	pool := s.pools[part]
+	return pool.find(s.rand.Float64() * pool.tree.total())
+}
+
+// grant picks the candidate to get a pass, and takes it out of its pool.
+func (s *state) grant(u int) {
+	part := s.assigned[u]
+	s.granted[u] = true
+	s.numGranted[part]++
+	if s.pools != nil {
+		s.pools[part].tree.remove(s.poolPos[u])
+	}
+}
+
+// refuseInReverse refuses the users from last to first,
+// so the first user ends up on top of the waitlist.
+func (s *state) refuseInReverse(users []int) {
+	for i := len(users) - 1; i >= 0; i-- {
+		s.refuse(users[i])
+	}
+}
+
+type backward struct{}
+
+func (backward) Name() string { return "backward" }
+
+// drawStage refuses candidates round robin over all partitions until every partition
+// has enough passes for its candidates.
+func (backward) drawStage(s *state, available []int) {
+	s.fillPools(s.p.refusalWeights)
+	partitionNeedsRefusals := make([]bool, len(s.p.partitions))
+	for part := range partitionNeedsRefusals {
+		partitionNeedsRefusals[part] = true
+	}
+
+	madeProgress := true
+	for madeProgress {
+		madeProgress = false
+		s.nextRound()
+
+		for part, isOpen := range partitionNeedsRefusals {
+			if !isOpen {
+				continue
+			}
+
+			// Check if this partition is still open.
+			// We need to check here, because other partitions might
+			// have refused enough users here to close it.
+			// Users that are not refused get a pass.
+			if available[part]-s.holders[part] >= s.numCandidates[part] {
+				// We refused enough users
+				partitionNeedsRefusals[part] = false
+				s.closePartition(part)
+				continue
+			}
+
+			// Find next refusal
+			u, ok := s.draw(part)
+			if ok && s.refuse(u) {
+				madeProgress = true
+				continue
+			}
+
+			// We run out of users to refuse.
+			log.Warningf("Refused all %d possible users for partition %s\n", len(s.pools[part].users), s.p.partitions[part])
+			partitionNeedsRefusals[part] = false
+			s.closePartition(part)
+		}
+	}
+}
+
+type forward struct{}
+
+func (forward) Name() string { return "forward" }
+
+// drawStage grants passes round robin over all partitions until every partition is full.
+// Granted users can still be refused if one of their dependencies is refused,
+// which leaves their pass unused.
+func (forward) drawStage(s *state, available []int) {
+	s.fillPools(s.p.grantWeights)
+
+	madeProgress := true
+	for madeProgress {
+		madeProgress = false
+		s.nextRound()
+
+		for part := range s.p.partitions {
+			free := available[part] - s.holders[part]
+			if free >= s.numCandidates[part] {
+				// All candidates get a pass.
+				s.closePartition(part)
+				continue
+			}
+
+			if s.numGranted[part] < free {
+				if u, ok := s.draw(part); ok {
+					s.grant(u)
+					madeProgress = true
+				}
+				continue
+			}
+
+			// The partition is full; the order the remaining users are drawn
+			// in decides their place on the waitlist.
+			var losers []int
+			for {
+				u, ok := s.draw(part)
+				if !ok {
+					break
+				}
+				s.pools[part].tree.remove(s.poolPos[u])
+				losers = append(losers, u)
+			}
+			s.refuseInReverse(losers)
+			madeProgress = true
+		}
+	}
+}
+
+type ticketOrder struct{}
+
+func (ticketOrder) Name() string { return "ticket-order" }
+
+// drawStage grants passes to candidates in the order of random tickets,
+// as long as their partition has room.
+func (ticketOrder) drawStage(s *state, available []int) {
+	type ticket struct {
+		user int
+		key  float64
+	}
+	// Weighted random order by Efraimidis and Spirakis:
+	// sorting by u^(1/w) for uniform u makes heavier users come first more often.
+	var tickets []ticket
+	for u, isCandidate := range s.candidate {
+		if isCandidate {
+			tickets = append(tickets, ticket{
+				user: u,
+				key:  math.Pow(s.rand.Float64(), s.p.refusalWeights[u]),
+			})
+		}
+	}
+	slices.SortStableFunc(tickets, func(a, b ticket) int {
+		return cmp.Compare(b.key, a.key)
+	})
+
+	// All losers are refused in a single round.
+	s.nextRound()
+	var losers []int
+	for _, t := range tickets {
+		if !s.candidate[t.user] {
+			continue
+		}
+		part := s.assigned[t.user]
+		if s.numGranted[part] < available[part]-s.holders[part] {
+			s.grant(t.user)
+		} else {
+			losers = append(losers, t.user)
+		}
+	}
+	s.refuseInReverse(losers)
+}
@@ -0,0 +1,81 @@
+package runner
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"slices"
+)
+
+// Tickets returns the ticket of every user for the given ticket seed.
+// Tickets are uniformly distributed in (0, 1); the higher the ticket,
+// the later a user is refused.
+func (p *Problem) Tickets(seed int64) map[UserID]float64 {
+	tickets := make(map[UserID]float64, len(p.users))
+	for _, u := range p.users {
+		tickets[u.ID] = ticket(seed, u.ID)
+	}
+	return tickets
+}
+
+// ticket derives the ticket of the user from the first 53 bits of sha256(seed, id).
+func ticket(seed int64, id UserID) float64 {
+	h := sha256.New()
+	binary.Write(h, binary.BigEndian, seed)
+	h.Write([]byte(id))
+	bits := binary.BigEndian.Uint64(h.Sum(nil)) >> 11
+	return (float64(bits) + 0.5) / (1 << 53)
+}
+
+type tickets struct{}
+
+func (tickets) Name() string { return "tickets" }
+
+// drawStage refuses candidates round robin over all partitions like the backward algorithm,
+// but always refuses the candidate with the lowest ticket key of the partition.
+//
+// Keys are weighted by Efraimidis and Spirakis: -ln(1-t) * weight for ticket t.
+// Refusing by ascending keys is the same as drawing refusals by refusal weight.
+func (tickets) drawStage(s *state, available []int) {
+	if s.ticketKeys == nil {
+		s.ticketSeed = s.rand.Int63()
+		s.ticketKeys = make([]float64, len(s.p.users))
+		for u, user := range s.p.users {
+			s.ticketKeys[u] = -math.Log1p(-ticket(s.ticketSeed, user.ID)) * s.p.grantWeights[u]
+		}
+	}
+
+	order := make([][]int, len(s.p.partitions))
+	for u, isCandidate := range s.candidate {
+		if isCandidate {
+			order[s.assigned[u]] = append(order[s.assigned[u]], u)
+		}
+	}
+	for _, users := range order {
+		slices.SortFunc(users, func(a, b int) int {
+			return cmp.Compare(s.ticketKeys[a], s.ticketKeys[b])
+		})
+	}
+
+	next := make([]int, len(s.p.partitions))
+	madeProgress := true
+	for madeProgress {
+		madeProgress = false
+		s.nextRound()
+
+		for part, users := range order {
+			if available[part]-s.holders[part] >= s.numCandidates[part] {
+				s.closePartition(part)
+				continue
+			}
+			// Skip users that were refused by cascades.
+			for next[part] < len(users) && !s.candidate[users[next[part]]] {
+				next[part]++
+			}
+			if next[part] < len(users) && s.refuse(users[next[part]]) {
+				madeProgress = true
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package runner
+
+import "slices"
+
+// Reason tells why a user was refused.
+type Reason string
+
+const (
+	// ReasonDrawn users were drawn by the strategy.
+	ReasonDrawn Reason = "drawn"
+	// ReasonExcluded users were refused before the draw, see Excluded.
+	ReasonExcluded Reason = "excluded"
+	// ReasonDependency users were refused because one of their Deps or DepGroups
+	// could no longer be satisfied.
+	ReasonDependency Reason = "dependency"
+	// ReasonGroup users were refused together with another member of their group.
+	ReasonGroup Reason = "group"
+)
+
+// Refusal explains why a user was refused for a partition.
+type Refusal struct {
+	Partition Partition
+	// Stage is the stage of the draw the user was refused in, starting at 0.
+	// Users are candidates for their n-th fallback in stage n at the earliest.
+	Stage int
+	// Round is the round of the stage the user was refused in, starting at 1.
+	// In every round, the strategy refuses at most one user of every partition.
+	// Round 0 is before the draw of the stage, e.g. for excluded users.
+	Round  int
+	Reason Reason
+	// Chain lists the users the refusal cascaded through, starting with the user
+	// that was refused directly, and ending with the user that caused this refusal.
+	// It is empty for users that were refused directly, and for users whose dependencies
+	// could not be met when they became candidates for a fallback, without a single refused
+	// user to blame, e.g. a DepGroup with fewer Users than its Min.
+	Chain []UserID `json:",omitempty"`
+}
+
+// Cascaded returns true if the user was refused because another user was refused.
+func (r Refusal) Cascaded() bool {
+	return len(r.Chain) > 0
+}
+
+// tracedRefusal is a refusal recorded during the draw; see Refusal.
+type tracedRefusal struct {
+	user int
+	part int
+	// cause is the index of the refusal that caused this one, or -1.
+	cause  int
+	reason Reason
+	stage  int
+	round  int
+}
+
+// startTrace makes the state record all following refusals.
+func (s *state) startTrace() {
+	s.refusalTrace = make([]tracedRefusal, 0, len(s.p.users))
+	s.lastRefusal = make([]int, len(s.p.users))
+}
+
+// trace records the refusal of user u, because of the refusal of user cause, or -1.
+// It must be called before u is refused, while it is still assigned to the partition.
+func (s *state) trace(u int, cause int, reason Reason) {
+	if s.refusalTrace == nil {
+		return
+	}
+	r := tracedRefusal{
+		user:   u,
+		part:   s.assigned[u],
+		cause:  -1,
+		reason: reason,
+		stage:  s.stage,
+		round:  s.round,
+	}
+	if cause >= 0 {
+		r.cause = s.lastRefusal[cause]
+	}
+	s.lastRefusal[u] = len(s.refusalTrace)
+	s.refusalTrace = append(s.refusalTrace, r)
+}
+
+// tracedRefusals returns the recorded refusals of every user, or nil if there are none.
+func (s *state) tracedRefusals() map[UserID][]Refusal {
+	if len(s.refusalTrace) == 0 {
+		return nil
+	}
+	refusals := make(map[UserID][]Refusal)
+	chains := make([][]UserID, len(s.refusalTrace))
+	for i, r := range s.refusalTrace {
+		if r.cause >= 0 {
+			// Causes are recorded before their effects, so their chain is known.
+			cause := s.p.users[s.refusalTrace[r.cause].user].ID
+			chains[i] = append(slices.Clip(chains[r.cause]), cause)
+		}
+		id := s.p.users[r.user].ID
+		refusals[id] = append(refusals[id], Refusal{
+			Partition: s.p.partitions[r.part],
+			Stage:     r.stage,
+			Round:     r.round,
+			Reason:    r.reason,
+			Chain:     chains[i],
+		})
+	}
+	return refusals
+}
+
+// chain returns the chain of the i-th recorded refusal; see Refusal.
+func (s *state) chain(i int) []UserID {
+	var chain []UserID
+	for cause := s.refusalTrace[i].cause; cause >= 0; cause = s.refusalTrace[cause].cause {
+		chain = append(chain, s.p.users[s.refusalTrace[cause].user].ID)
+	}
+	slices.Reverse(chain)
+	return chain
+}
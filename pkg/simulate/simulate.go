@@ -0,0 +1,226 @@
+// Package simulate runs many draws of the same problem in parallel and collects statistics.
+package simulate
+
+import (
+	"context"
+	"math/rand"
+	"runtime"
+	"sync"
+
+	"github.com/wchresta/passdraw/pkg/runner"
+)
+
+// chunkSize is the amount of runs that share a random stream.
+// Splitting runs into fixed chunks makes results independent of the amount of workers.
+const chunkSize = 256
+
+type Options struct {
+	// Runs is the amount of draws to simulate.
+	Runs int
+	// Workers is the amount of goroutines to use; defaults to GOMAXPROCS.
+	Workers int
+	// Seed makes the result reproducible, whatever the amount of workers.
+	Seed int64
+	// Strategy draws the passes; defaults to runner.Backward.
+	// Using the same seed for different strategies compares them on the same random streams.
+	Strategy runner.Strategy
+	// Progress, if not nil, is called with the amount of finished runs after every chunk of runs.
+	// It is never called concurrently.
+	Progress func(done, total int)
+}
+
+type Result struct {
+	Runs int
+	// Passes counts in how many runs each user got a pass, by partition.
+	Passes map[runner.Partition]map[runner.UserID]int
+	// Handed counts the passes handed out over all runs, by partition.
+	Handed map[runner.Partition]int
+	// Underfilled counts the runs in which a partition handed out fewer passes than
+	// available, while users were left on its waitlist.
+	Underfilled map[runner.Partition]int
+}
+
+// Probability returns the estimated probability for the user to get a pass for the partition.
+func (r *Result) Probability(part runner.Partition, id runner.UserID) float64 {
+	if r.Runs == 0 {
+		return 0
+	}
+	return float64(r.Passes[part][id]) / float64(r.Runs)
+}
+
+// UserPasses returns in how many runs the user got a pass for any partition.
+func (r *Result) UserPasses(id runner.UserID) int {
+	n := 0
+	for _, partPass := range r.Passes {
+		n += partPass[id]
+	}
+	return n
+}
+
+// UserProbability returns the estimated probability for the user to get a pass for any partition.
+func (r *Result) UserProbability(id runner.UserID) float64 {
+	if r.Runs == 0 {
+		return 0
+	}
+	return float64(r.UserPasses(id)) / float64(r.Runs)
+}
+
+// ExpectedHanded returns the expected amount of passes handed out for the partition.
+func (r *Result) ExpectedHanded(part runner.Partition) float64 {
+	if r.Runs == 0 {
+		return 0
+	}
+	return float64(r.Handed[part]) / float64(r.Runs)
+}
+
+// UnderfilledProbability returns the estimated probability that the partition is under-filled.
+func (r *Result) UnderfilledProbability(part runner.Partition) float64 {
+	if r.Runs == 0 {
+		return 0
+	}
+	return float64(r.Underfilled[part]) / float64(r.Runs)
+}
+
+// Run simulates draws of the problem until opts.Runs are done, or ctx is canceled.
+// If ctx is canceled, the context's error is returned.
+func Run(ctx context.Context, p *runner.Problem, availabilities []runner.Availability, opts Options) (*Result, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	strategy := opts.Strategy
+	if strategy == nil {
+		strategy = runner.Backward
+	}
+	numChunks := (opts.Runs + chunkSize - 1) / chunkSize
+	available := make(map[runner.Partition]int)
+	for _, a := range availabilities {
+		available[a.Partition] = a.Available
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	chunks := make(chan int)
+	go func() {
+		defer close(chunks)
+		for i := range numChunks {
+			select {
+			case chunks <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+		done     int
+		total    = newResult()
+		wg       sync.WaitGroup
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			local := newResult()
+			for chunk := range chunks {
+				runs := min(chunkSize, opts.Runs-chunk*chunkSize)
+				err := runChunk(ctx, p, strategy, availabilities, available, chunkSeed(opts.Seed, chunk), runs, local)
+
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					cancel()
+				} else {
+					done += runs
+					if opts.Progress != nil {
+						opts.Progress(done, opts.Runs)
+					}
+				}
+				mu.Unlock()
+			}
+
+			mu.Lock()
+			total.merge(local)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return total, nil
+}
+
+func runChunk(ctx context.Context, p *runner.Problem, strategy runner.Strategy, availabilities []runner.Availability, available map[runner.Partition]int, seed int64, runs int, res *Result) error {
+	rnd := rand.New(rand.NewSource(seed))
+	for range runs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		solution, err := p.RunUntraced(strategy, rnd, availabilities)
+		if err != nil {
+			return err
+		}
+		res.add(solution, available)
+	}
+	return nil
+}
+
+func newResult() *Result {
+	return &Result{
+		Passes:      make(map[runner.Partition]map[runner.UserID]int),
+		Handed:      make(map[runner.Partition]int),
+		Underfilled: make(map[runner.Partition]int),
+	}
+}
+
+func (r *Result) add(s *runner.Solution, available map[runner.Partition]int) {
+	r.Runs++
+	for part, partPass := range s.Passes {
+		r.Handed[part] += len(partPass)
+		if len(partPass) < available[part] && len(s.Waitlist[part]) > 0 {
+			r.Underfilled[part]++
+		}
+		if _, ok := r.Passes[part]; !ok {
+			r.Passes[part] = make(map[runner.UserID]int)
+		}
+		for _, u := range partPass {
+			r.Passes[part][u]++
+		}
+	}
+}
+
+func (r *Result) merge(other *Result) {
+	r.Runs += other.Runs
+	for part, n := range other.Handed {
+		r.Handed[part] += n
+	}
+	for part, n := range other.Underfilled {
+		r.Underfilled[part] += n
+	}
+	for part, partPass := range other.Passes {
+		if _, ok := r.Passes[part]; !ok {
+			r.Passes[part] = make(map[runner.UserID]int)
+		}
+		for u, n := range partPass {
+			r.Passes[part][u] += n
+		}
+	}
+}
+
+// chunkSeed derives an independent seed for every chunk with SplitMix64.
+func chunkSeed(seed int64, chunk int) int64 {
+	z := uint64(seed) + uint64(chunk+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
@@ -0,0 +1,96 @@
+package simulate_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/wchresta/passdraw/pkg/runner"
+	"github.com/wchresta/passdraw/pkg/simulate"
+)
+
+func mkProblem() (*runner.Problem, []runner.Availability) {
+	var users []runner.User
+	for i := range 30 {
+		users = append(users, runner.User{Partition: "Left", ID: runner.UserID(fmt.Sprintf("L%d", i))})
+		users = append(users, runner.User{Partition: "Right", ID: runner.UserID(fmt.Sprintf("R%d", i))})
+	}
+	users = append(users,
+		runner.User{Partition: "Left", ID: "CoupleL", Deps: []runner.UserID{"CoupleR"}},
+		runner.User{Partition: "Right", ID: "CoupleR", Deps: []runner.UserID{"CoupleL"}},
+	)
+	return runner.Compile(users), []runner.Availability{
+		{Partition: "Left", Available: 20},
+		{Partition: "Right", Available: 25},
+	}
+}
+
+func TestRun_DeterministicForAnyWorkerCount(t *testing.T) {
+	p, avail := mkProblem()
+
+	var want *simulate.Result
+	for _, workers := range []int{1, 3, 8} {
+		got, err := simulate.Run(context.Background(), p, avail, simulate.Options{
+			Runs:    2000,
+			Workers: workers,
+			Seed:    5544332211,
+		})
+		if err != nil {
+			t.Fatalf("Run failed unexpectedly: %s", err)
+		}
+		if got.Runs != 2000 {
+			t.Errorf("Run did %d runs, want 2000", got.Runs)
+		}
+		if want == nil {
+			want = got
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Run with %d workers produced a different result", workers)
+		}
+	}
+
+	if prob := want.Probability("Left", "L0"); prob < 0.5 || prob > 0.8 {
+		t.Errorf("Unexpected probability for L0: %f", prob)
+	}
+}
+
+func TestRun_Progress(t *testing.T) {
+	p, avail := mkProblem()
+
+	last := 0
+	_, err := simulate.Run(context.Background(), p, avail, simulate.Options{
+		Runs:    1000,
+		Workers: 4,
+		Progress: func(done, total int) {
+			if done <= last || total != 1000 {
+				t.Errorf("Unexpected progress: %d of %d after %d", done, total, last)
+			}
+			last = done
+		},
+	})
+	if err != nil {
+		t.Fatalf("Run failed unexpectedly: %s", err)
+	}
+	if last != 1000 {
+		t.Errorf("Progress ended at %d, want 1000", last)
+	}
+}
+
+func TestRun_Cancel(t *testing.T) {
+	p, avail := mkProblem()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	_, err := simulate.Run(ctx, p, avail, simulate.Options{
+		Runs:    1_000_000,
+		Workers: 2,
+		Progress: func(done, total int) {
+			cancel()
+		},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Run returned unexpected error: got %v, want %v", err, context.Canceled)
+	}
+}